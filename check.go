@@ -0,0 +1,546 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync/atomic"
+	"text/template"
+	"time"
+
+	"github.com/aleister1102/timetraveller/pkg/timetraveller"
+)
+
+// checkFlags holds the options of the "check" command.
+type checkFlags struct {
+	engineFlags
+	noErrorFilter  bool
+	latestSnapshot bool
+	outputFile     string
+	stateFile      string
+	resumeFile     string
+	dbFile         string
+	diffRun        string
+	every          time.Duration
+	changedOnly    bool
+	atDates        stringSliceFlag
+	atWindowDays   int
+	detailsLink    bool
+	mimePreference string
+	verifyMap      string
+	dedupResults   bool
+	maxPerHost     int
+	unicode        bool
+	noProgress     bool
+	tui            bool
+	silent         bool
+	statsJSON      bool
+	jsonOutput     bool
+	jsonlOutput    bool
+	csvFile        string
+	sinks          sinkFlag
+	webhook        string
+	webhookBatch   int
+	webhookRetries int
+	esIndex        string
+	esBatch        int
+	esAPIKey       string
+	notify         notifyFlag
+	notifyFindings bool
+	format         string
+	allSnapshots   bool
+	changes        bool
+	closest        string
+	countOnly      bool
+	fast           bool
+	saveMissing    bool
+	spnKey         string
+}
+
+// register adds the flags of the "check" command, which "watch" shares.
+func (f *checkFlags) register(fs *flag.FlagSet) {
+	f.engineFlags.register(fs)
+	fs.BoolVar(&f.noErrorFilter, "no-err", false, "Filter out 'not found' and error results")
+	fs.BoolVar(&f.latestSnapshot, "latest", false, "Get the latest snapshot instead of the oldest")
+	fs.BoolVar(&f.countOnly, "count-only", false, "Only count captures, fetching just their timestamps (fast triage)")
+	fs.BoolVar(&f.fast, "fast", false, "Use the lightweight availability API: one snapshot per URL, without counts or history")
+	fs.BoolVar(&f.saveMissing, "save-missing", false, "Submit URLs without snapshots to the Wayback Machine's Save Page Now API and report the new capture")
+	fs.StringVar(&f.spnKey, "spn-key", "", "Save Page Now API key as 'accesskey:secret' for higher limits (see archive.org/account/s3.php)")
+	fs.StringVar(&f.closest, "closest", "", "Get the snapshot closest to this timestamp (YYYY[MM[DD[hhmmss]]]) instead of the oldest")
+	fs.StringVar(&f.outputFile, "o", "", "File to write found snapshot URLs to")
+	fs.StringVar(&f.stateFile, "state", "", "File recording the latest capture timestamp of each URL between runs")
+	fs.StringVar(&f.dbFile, "db", "", "SQLite database to record every result and snapshot of the run in, alongside earlier runs")
+	fs.StringVar(&f.diffRun, "diff-run", "", "Earlier run to compare with (-json or -jsonl output, or a -db database): only print new findings, URLs now found or no longer found, and snapshot count changes")
+	fs.DurationVar(&f.every, "every", 0, "Run again after this long (e.g. 24h) until interrupted, instead of exiting after one pass")
+	fs.StringVar(&f.resumeFile, "resume", "", "File recording the URLs completed so far; a run started again with it skips them and appends to -o")
+	fs.BoolVar(&f.changedOnly, "changed-only", false, "Only print URLs whose latest capture is newer than in the -state file")
+	fs.Var(&f.atDates, "at", "Checkpoint date (YYYY, YYYYMM or YYYYMMDD) to report capture availability for (repeatable)")
+	fs.IntVar(&f.atWindowDays, "at-window", 30, "Days on either side of an -at date within which a capture counts as present")
+	fs.BoolVar(&f.detailsLink, "details", false, "Also print the Wayback calendar (details) link for found snapshots")
+	fs.StringVar(&f.mimePreference, "mime-preference", "", "Comma-separated mimetypes to prefer when selecting a snapshot (e.g. text/html,application/pdf)")
+	fs.StringVar(&f.verifyMap, "verify-map", "", "CSV file of 'original,expected archive URL' pairs to check against resolved snapshots")
+	fs.BoolVar(&f.dedupResults, "dedup-results", false, "Suppress results identical to one already printed")
+	fs.BoolVar(&f.jsonOutput, "json", false, "Print all results as a JSON array instead of colored text")
+	fs.BoolVar(&f.jsonlOutput, "jsonl", false, "Stream one JSON object per line as each result arrives")
+	fs.StringVar(&f.csvFile, "csv", "", "File to write every snapshot's CDX fields to as CSV")
+	fs.Var(&f.sinks, "sink", "Send results to a destination: stdout, file:PATH (found URLs), jsonl:PATH, csv:PATH, sqlite:PATH, webhook:URL or elasticsearch:URL (repeatable; stdout is dropped unless listed)")
+	fs.StringVar(&f.webhook, "webhook", "", "URL to POST each found result to as JSON, e.g. to feed alerting or ticketing")
+	fs.IntVar(&f.webhookBatch, "webhook-batch", 1, "Results per webhook POST; above 1, they are sent together as a JSON array")
+	fs.IntVar(&f.webhookRetries, "webhook-retries", 3, "Number of times to retry a webhook POST failing with a network error, 429 or 5xx")
+	fs.StringVar(&f.esIndex, "es-index", "timetraveller", "Elasticsearch/OpenSearch index that -sink elasticsearch:URL writes to")
+	fs.IntVar(&f.esBatch, "es-batch", 500, "Documents to queue before sending them to Elasticsearch in one bulk request")
+	fs.StringVar(&f.esAPIKey, "es-api-key", "", "Elasticsearch API key (base64 'id:key'); basic auth can be given as user:password in the URL instead")
+	fs.Var(&f.notify, "notify", "Chat channel to post the run summary to when it finishes, as slack://hooks.slack.com/services/... or discord://discord.com/api/webhooks/... (repeatable)")
+	fs.BoolVar(&f.notifyFindings, "notify-findings", false, "Also post each found result to the -notify channels as it arrives")
+	fs.BoolVar(&f.allSnapshots, "all", false, "List every snapshot of each URL instead of only the oldest or latest")
+	fs.BoolVar(&f.changes, "changes", false, "List only the snapshots where the content changed (by CDX digest)")
+	fs.StringVar(&f.format, "format", "", "Go template applied to each result, e.g. '{{.URL}} {{.SnapshotCount}} {{.OldestURL}}'")
+	fs.IntVar(&f.maxPerHost, "max-per-host", 0, "Maximum number of input URLs to queue per host (0 = unlimited)")
+	fs.BoolVar(&f.noProgress, "no-progress", false, "Do not show the live progress line on stderr when it is a terminal")
+	fs.BoolVar(&f.silent, "silent", false, "Print only the found snapshot URLs, one per line, with no colors or messages (for piping into other tools)")
+	fs.BoolVar(&f.statsJSON, "stats-json", false, "Print the end-of-run summary on stderr as one JSON object")
+	fs.BoolVar(&f.tui, "tui", false, "Show a full-screen dashboard of in-flight lookups, rate limiting and results; p pauses, q stops")
+	fs.BoolVar(&f.unicode, "unicode", false, "Print internationalized hostnames in their Unicode form instead of punycode")
+}
+
+func runCheck(args []string) {
+	var f checkFlags
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	f.register(fs)
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: timetraveller [check] [options] <url1> [url2 ...]\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "\nOr pipe URLs:\n")
+		fmt.Fprintf(os.Stderr, "  echo <url> | timetraveller [options]\n")
+		fmt.Fprintf(os.Stderr, "  cat list_of_urls.txt | timetraveller [options]\n")
+		fmt.Fprintf(os.Stderr, "\nRun 'timetraveller help' to list all commands.\n")
+	}
+	parseFlags(fs, args)
+	formatTemplate := f.validate(fs)
+
+	if fs.NArg() == 0 && !stdinPiped() && f.verifyMap == "" {
+		fs.Usage()
+		os.Exit(exitUsage)
+	}
+	input, err := f.inputURLs(fs.Args())
+	if err != nil {
+		usageFatalf("%v", err)
+	}
+	os.Exit(f.checkRepeatedly(input, formatTemplate))
+}
+
+// checkRepeatedly checks the input URLs once, or with -every, again after
+// each period until interrupted. The first pass streams the input; later
+// passes replay the URLs it read. It returns the exit code of the passes
+// together: exitFound if any found a snapshot, else exitAborted if every
+// lookup failed, else exitNotFound.
+func (f *checkFlags) checkRepeatedly(input <-chan string, formatTemplate *template.Template) int {
+	if f.silent {
+		silenceStderr()
+	}
+	var found, succeeded, failed int
+	exitCode := func() int {
+		switch {
+		case found > 0:
+			return exitFound
+		case failed > 0 && succeeded == 0:
+			return exitAborted
+		}
+		return exitNotFound
+	}
+	var replay []string
+	for pass := 0; ; pass++ {
+		urls := input
+		if pass > 0 {
+			urls = sendURLs(replay)
+		} else if f.every > 0 {
+			urls = filterURLs(input, func(u string) bool {
+				replay = append(replay, u)
+				return true
+			})
+		}
+		stats := f.check(urls, formatTemplate)
+		found += stats.found
+		succeeded += stats.found + stats.notFound
+		failed += stats.failed
+		if f.every <= 0 {
+			return exitCode()
+		}
+		stop := f.shutdown()
+		if stop.dispatch.Err() != nil {
+			return exitCode()
+		}
+		slog.Info("Next run scheduled", "at", time.Now().Add(f.every).Format(time.DateTime))
+		select {
+		case <-time.After(f.every):
+		case <-stop.dispatch.Done():
+			return exitCode()
+		}
+	}
+}
+
+// validate rejects conflicting flags and returns the -format template, if any.
+func (f *checkFlags) validate(fs *flag.FlagSet) *template.Template {
+	if countTrue(f.jsonOutput, f.jsonlOutput, f.format != "", f.silent) > 1 {
+		usageFatalf("Only one of -json, -jsonl, -format and -silent can be used")
+	}
+	if f.silent && f.tui {
+		usageFatalf("-silent cannot be combined with -tui")
+	}
+	var formatTemplate *template.Template
+	if f.format != "" {
+		var err error
+		if formatTemplate, err = template.New("format").Parse(f.format); err != nil {
+			usageFatalf("Invalid -format template: %v", err)
+		}
+	}
+
+	if f.countOnly && countTrue(f.allSnapshots, f.changes, f.csvFile != "" || f.sinks.has("csv"), len(f.atDates) > 0, f.stateFile != "", f.verifyMap != "") > 0 {
+		usageFatalf("-count-only cannot be combined with -all, -changes, -csv, -at, -state or -verify-map")
+	}
+	if f.fast {
+		// These need the CDX API's full capture list or query parameters.
+		for _, name := range []string{"all", "changes", "count-only", "at", "csv", "state", "mime-preference",
+			"filter", "any-status", "mime", "collapse", "page-size", "match", "from", "until", "timemap", "provider"} {
+			if flagPassed(fs, name) {
+				usageFatalf("-fast cannot be combined with -%s", name)
+			}
+		}
+	}
+	if f.webhook != "" {
+		if err := validateWebhookURL(f.webhook); err != nil {
+			usageFatalf("Invalid -webhook %q: %v", f.webhook, err)
+		}
+	}
+	if f.webhookBatch < 1 || f.webhookRetries < 0 {
+		usageFatalf("-webhook-batch must be at least 1 and -webhook-retries at least 0")
+	}
+	if f.esIndex == "" || f.esIndex != strings.ToLower(f.esIndex) || f.esBatch < 1 {
+		usageFatalf("-es-index must be a lowercase index name and -es-batch at least 1")
+	}
+	if f.notifyFindings && len(f.notify) == 0 {
+		usageFatalf("-notify-findings requires -notify")
+	}
+	if f.every > 0 && f.resumeFile != "" {
+		usageFatalf("-every cannot be combined with -resume")
+	}
+	if f.closest != "" {
+		if f.latestSnapshot {
+			usageFatalf("-latest and -closest cannot be used together")
+		}
+		if _, err := timetraveller.ParseTimestamp(f.closest); err != nil || !isDigits(f.closest) {
+			usageFatalf("Invalid -closest %q: expected a timestamp like 20190401", f.closest)
+		}
+	}
+	return formatTemplate
+}
+
+// prepareMapping re-keys a -verify-map mapping by the URLs prepareInput turns
+// its originals into, which are the URLs the results report.
+func (f *checkFlags) prepareMapping(mapping map[string]string) map[string]string {
+	prepared := make(map[string]string, len(mapping))
+	for original, expected := range mapping {
+		if validateInputURL(original) != nil {
+			// Reported when the original itself is read.
+			continue
+		}
+		urls, _ := f.prepareInput(sendURLs([]string{original}))
+		for u := range urls {
+			prepared[u] = expected
+		}
+	}
+	return prepared
+}
+
+// check looks up the URLs received on urls, reports the results and returns
+// their tallies.
+func (f *checkFlags) check(urls <-chan string, formatTemplate *template.Template) *runStats {
+	var err error
+	if f.changedOnly && f.stateFile == "" {
+		usageFatalf("-changed-only requires -state")
+	}
+	var state runState
+	if f.stateFile != "" {
+		if state, err = loadState(f.stateFile); err != nil {
+			fatalf("Error reading state file: %v", err)
+		}
+	}
+
+	var checkpoints []time.Time
+	for _, date := range f.atDates {
+		checkpoint, err := timetraveller.ParseTimestamp(date)
+		if err != nil {
+			usageFatalf("Invalid -at date: %v", err)
+		}
+		checkpoints = append(checkpoints, checkpoint)
+	}
+	atWindow := time.Duration(f.atWindowDays) * 24 * time.Hour
+
+	var expectedArchiveURLs map[string]string
+	if f.verifyMap != "" {
+		var mappedURLs []string
+		expectedArchiveURLs, mappedURLs, err = readArchiveMapping(f.verifyMap)
+		if err != nil {
+			fatalf("Error reading verify map: %v", err)
+		}
+		urls = concatURLs(urls, mappedURLs)
+		expectedArchiveURLs = f.prepareMapping(expectedArchiveURLs)
+	}
+
+	urls, inputStats := f.prepareInput(urls)
+
+	var perHost *hostCap
+	if f.maxPerHost > 0 {
+		perHost = newHostCap(f.maxPerHost)
+		urls = filterURLs(urls, perHost.admit)
+	}
+
+	var resume *checkpoint
+	var resumed atomic.Int64
+	if f.resumeFile != "" {
+		if resume, err = openCheckpoint(f.resumeFile); err != nil {
+			fatalf("Error reading resume file: %v", err)
+		}
+		defer resume.Close()
+		urls = filterURLs(urls, func(u string) bool {
+			if resume.completed[u] {
+				resumed.Add(1)
+				return false
+			}
+			return true
+		})
+	}
+
+	fetchOpts, err := f.lookupOptions()
+	if err != nil {
+		usageFatalf("%v", err)
+	}
+	fetchOpts.Latest = f.latestSnapshot
+	fetchOpts.Closest = f.closest
+	fetchOpts.CountOnly = f.countOnly
+	fetchOpts.Availability = f.fast
+	fetchOpts.MimePreference = splitList(f.mimePreference)
+	fetchOpts.DetailsLink = f.detailsLink
+
+	var previous previousRun
+	if f.diffRun != "" {
+		// Loaded before -db records this run, which may share the database.
+		if previous, err = loadPreviousRun(f.diffRun); err != nil {
+			fatalf("Error reading -diff-run results: %v", err)
+		}
+	}
+
+	records, outputs, err := f.openSinks(fetchOpts, resume != nil)
+	if err != nil {
+		fatalf("Error %v", err)
+	}
+
+	var bar *progress
+	var ui *dashboard
+	stdout := io.Writer(os.Stdout)
+	if f.tui {
+		if ui, err = newDashboard(f.shutdown()); err != nil {
+			fatalf("%v", err)
+		}
+		defer ui.Close()
+		urls = ui.count(urls)
+		f.dispatched = ui.dispatched
+		client := f.client()
+		onRateLimited := client.OnRateLimited
+		client.OnRateLimited = func(consecutive int) {
+			ui.limited(consecutive)
+			if onRateLimited != nil {
+				onRateLimited(consecutive)
+			}
+		}
+		defer func() {
+			f.dispatched = nil
+			client.OnRateLimited = onRateLimited
+		}()
+		if isTerminal(os.Stdout) {
+			// Results go to the dashboard instead of over it.
+			stdout = io.Discard
+		}
+	} else if !f.noProgress {
+		bar = newProgress()
+		defer bar.Close()
+		urls = bar.count(urls)
+	}
+
+	stats := newRunStats(f.client())
+	resultsChan := startLookups(&f.engineFlags, urls, fetchOpts)
+
+	notifiers := f.newNotifiers()
+	if f.notifyFindings {
+		for _, n := range notifiers {
+			outputs.add(n.service, n)
+		}
+	}
+	printing := len(f.sinks) == 0 || f.sinks.has("stdout")
+	if printing {
+		outputs.add("stdout", newPrintSink(f, stdout, ui, formatTemplate, checkpoints, atWindow))
+	}
+	seenResults := make(map[[sha256.Size]byte]struct{})
+
+	emit := func(out checkResult) {
+		if f.noErrorFilter {
+			if out.Error != nil {
+				return
+			}
+			if out.Status == timetraveller.StatusNotFound && out.SavedURL == "" {
+				return
+			}
+		}
+		if err := outputs.Write(out); err != nil {
+			fatalf("Error writing to %v", err)
+		}
+	}
+	// done records a URL as completed once its result has reached the
+	// outputs, or was filtered out. Failed lookups are retried by the next run.
+	done := func(result timetraveller.ProcessResult) {
+		if resume == nil || result.Error != nil {
+			return
+		}
+		if err := resume.add(result.URL); err != nil {
+			fatalf("Error writing resume file: %v", err)
+		}
+	}
+	// emitSaved emits a -save-missing result, whose URL was left out of the
+	// checkpoint until its capture finished.
+	emitSaved := func(out checkResult) {
+		emit(out)
+		done(out.ProcessResult)
+	}
+	// filter applies -dedup-results, -changed-only and -diff-run, updating
+	// the -state, and returns the result to emit if it is kept.
+	filter := func(result timetraveller.ProcessResult) (checkResult, bool) {
+		out := checkResult{ProcessResult: result}
+		out.Expected, out.Verify = expectedArchiveURLs[result.URL]
+		if f.dedupResults {
+			key := resultKey(result)
+			if _, seen := seenResults[key]; seen {
+				return out, false
+			}
+			seenResults[key] = struct{}{}
+		}
+
+		if state != nil && result.Status == timetraveller.StatusFound {
+			latest := result.LatestTimestamp()
+			previous, known := state[result.URL]
+			if latest > previous {
+				state[result.URL] = latest
+			}
+			if f.changedOnly && known && latest <= previous {
+				return out, false
+			}
+		}
+		if f.changedOnly && result.Error == nil && result.Status != timetraveller.StatusFound {
+			return out, false
+		}
+		if previous != nil {
+			if out.Change = previous.change(result); out.Change == "" {
+				return out, false
+			}
+		}
+		return out, true
+	}
+	var saves *savePool
+	if f.saveMissing {
+		client := f.client()
+		saves = newSavePool(f.shutdown().requests, saveWorkers, func(ctx context.Context, u string) (string, error) {
+			return client.SavePage(ctx, u, f.spnKey)
+		})
+	}
+
+	// Process the results and hand them to the sinks
+	for {
+		result, ok := bar.next(resultsChan)
+		if !ok {
+			break
+		}
+		if ui != nil {
+			ui.add(result)
+		}
+		if saves != nil {
+			saves.poll(emitSaved)
+		}
+		// Everything recorded in the checkpoint so far has been written out.
+		if resume != nil && resume.due() {
+			if err := outputs.Flush(); err != nil {
+				fatalf("Error writing to %v", err)
+			}
+			if err := resume.sync(); err != nil {
+				fatalf("Error writing resume file: %v", err)
+			}
+		}
+
+		if err := records.Write(checkResult{ProcessResult: result}); err != nil {
+			fatalf("Error writing to %v", err)
+		}
+
+		stats.add(result)
+
+		out, keep := filter(result)
+		if keep && saves != nil && result.Error == nil && result.Status == timetraveller.StatusNotFound {
+			saves.submit(out, emitSaved)
+			continue
+		}
+		if keep {
+			emit(out)
+		}
+		done(result)
+	}
+	if saves != nil {
+		saves.drain(emitSaved)
+	}
+	if ui != nil {
+		ui.Close()
+	}
+
+	if state != nil {
+		if err := saveState(f.stateFile, state); err != nil {
+			fatalf("Error writing state file: %v", err)
+		}
+	}
+
+	if err := records.Close(); err != nil {
+		fatalf("Error writing to %v", err)
+	}
+	if err := outputs.Close(); err != nil {
+		fatalf("Error writing to %v", err)
+	}
+	for _, snk := range outputs {
+		if file, ok := snk.sink.(*urlSink); ok && file.file.count > 0 {
+			if f.jsonOutput || f.jsonlOutput || formatTemplate != nil || f.silent || !printing {
+				// Keep stdout machine-readable.
+				slog.Info("Successfully wrote found URLs", "count", file.file.count, "file", file.path)
+			} else {
+				fmt.Printf(ColorBlue+"\n[i] Successfully wrote %d found URLs to %s\n"+ColorReset, file.file.count, file.path)
+			}
+		}
+	}
+
+	inputStats.report()
+	if n := resumed.Load(); n > 0 {
+		slog.Info("Skipped URLs completed by an earlier run", "count", n)
+	}
+	if perHost != nil {
+		if n := perHost.skipped.Load(); n > 0 {
+			slog.Warn("Skipped URLs exceeding -max-per-host", "count", n, "max_per_host", f.maxPerHost)
+		}
+	}
+	f.reportInterrupted("%d URLs processed (%d found, %d not found, %d errors)",
+		stats.processed, stats.found, stats.notFound, stats.failed)
+	stats.report(f.statsJSON)
+	for _, n := range notifiers {
+		n.summary(stats.summary(), f.interrupted())
+	}
+	return stats
+}
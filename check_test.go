@@ -0,0 +1,379 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// cdxHeader is the header row of CDX JSON output.
+var cdxHeader = []string{"urlkey", "timestamp", "original", "mimetype", "statuscode", "digest", "length"}
+
+// newCDXServer serves CDX JSON output listing captures[url] for each queried
+// url, as a self-hosted archive queried with -cdx-url would.
+func newCDXServer(t *testing.T, captures map[string][][]string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rows := [][]string{cdxHeader}
+		rows = append(rows, captures[r.URL.Query().Get("url")]...)
+		json.NewEncoder(w).Encode(rows)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// capture returns a CDX row of original captured at timestamp.
+func capture(original, timestamp, mimetype, digest string) []string {
+	return []string{"key", timestamp, original, mimetype, "200", digest, "100"}
+}
+
+// runTestCheck runs one pass of "check" with args over urls, querying server,
+// and returns the lines it printed on stdout.
+func runTestCheck(t *testing.T, server *httptest.Server, args []string, urls ...string) []string {
+	t.Helper()
+	var f checkFlags
+	fs := flag.NewFlagSet("check", flag.ContinueOnError)
+	f.register(fs)
+	args = append([]string{"-no-progress", "-color", "never", "-retries", "0", "-cdx-url", server.URL}, args...)
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	if err := setupColor(fs); err != nil {
+		t.Fatal(err)
+	}
+	formatTemplate := f.validate(fs)
+	out := captureStdout(t, func() {
+		f.check(sendURLs(urls), formatTemplate)
+	})
+	if out = strings.TrimSpace(out); out == "" {
+		return nil
+	}
+	return strings.Split(out, "\n")
+}
+
+// captureStdout returns what run writes to os.Stdout.
+func captureStdout(t *testing.T, run func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	done := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		done <- string(data)
+	}()
+	defer func() {
+		os.Stdout = stdout
+	}()
+	run()
+	w.Close()
+	return <-done
+}
+
+func TestCheckDedupResults(t *testing.T) {
+	server := newCDXServer(t, map[string][][]string{
+		"https://a.example/": {capture("https://a.example/", "20010101000000", "text/html", "A")},
+		"https://b.example/": {capture("https://b.example/", "20020101000000", "text/html", "B")},
+	})
+	tests := []struct {
+		name      string
+		args      []string
+		urls      []string
+		wantLines int
+	}{
+		{"identical results printed once", []string{"-keep-duplicates", "-dedup-results"}, []string{"https://a.example/", "https://a.example/"}, 1},
+		{"identical results without -dedup-results", []string{"-keep-duplicates"}, []string{"https://a.example/", "https://a.example/"}, 2},
+		{"different results kept", []string{"-keep-duplicates", "-dedup-results"}, []string{"https://a.example/", "https://b.example/"}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := runTestCheck(t, server, append(tt.args, "-jsonl"), tt.urls...)
+			if len(lines) != tt.wantLines {
+				t.Errorf("printed %d lines, want %d:\n%s", len(lines), tt.wantLines, strings.Join(lines, "\n"))
+			}
+			for _, line := range lines {
+				if !strings.Contains(line, `"status":"found"`) {
+					t.Errorf("unexpected result %s", line)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckVerifyMap(t *testing.T) {
+	server := newCDXServer(t, map[string][][]string{
+		"https://a.example/": {capture("https://a.example/", "20010101000000", "text/html", "A")},
+		"https://b.example/": {capture("https://b.example/", "20020101000000", "text/html", "B")},
+	})
+	mapping := filepath.Join(t.TempDir(), "mapping.csv")
+	err := os.WriteFile(mapping, []byte(strings.Join([]string{
+		"# original, expected archive URL",
+		"https://a.example/, https://web.archive.org/web/20010101000000/https://a.example/",
+		"https://b.example/, https://web.archive.org/web/19990101000000/https://b.example/",
+		"https://missing.example/, https://web.archive.org/web/20050101000000/https://missing.example/",
+	}, "\n")), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := runTestCheck(t, server, []string{"-verify-map", mapping})
+	want := map[string]string{
+		"https://a.example/":       "[=] https://a.example/ - Match: http://web.archive.org/web/20010101000000/https://a.example/",
+		"https://b.example/":       "[x] https://b.example/ - Mismatch: expected https://web.archive.org/web/19990101000000/https://b.example/, got http://web.archive.org/web/20020101000000/https://b.example/",
+		"https://missing.example/": "[x] https://missing.example/ - Mismatch: expected https://web.archive.org/web/20050101000000/https://missing.example/, got not found",
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("printed %d lines, want %d:\n%s", len(lines), len(want), strings.Join(lines, "\n"))
+	}
+	for _, line := range lines {
+		u := strings.Fields(line)[1]
+		if line != want[u] {
+			t.Errorf("got  %s\nwant %s", line, want[u])
+		}
+	}
+}
+
+func TestCheckVerifyMapPreparedURLs(t *testing.T) {
+	server := newCDXServer(t, map[string][][]string{
+		"https://a.example/":             {capture("https://a.example/", "20010101000000", "text/html", "A")},
+		"https://xn--bcher-kva.example/": {capture("https://xn--bcher-kva.example/", "20020101000000", "text/html", "B")},
+	})
+	mapping := filepath.Join(t.TempDir(), "mapping.csv")
+	err := os.WriteFile(mapping, []byte(strings.Join([]string{
+		"HTTPS://A.EXAMPLE/#top, https://web.archive.org/web/20010101000000/https://a.example/",
+		"https://bücher.example/, https://web.archive.org/web/20020101000000/https://xn--bcher-kva.example/",
+	}, "\n")), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{"originals prepared for lookup", []string{"-ignore-fragment"}, []string{
+			"[=] https://a.example/ - Match: http://web.archive.org/web/20010101000000/https://a.example/",
+			"[=] https://xn--bcher-kva.example/ - Match: http://web.archive.org/web/20020101000000/https://xn--bcher-kva.example/",
+		}},
+		{"Unicode hostnames", []string{"-ignore-fragment", "-unicode"}, []string{
+			"[=] https://a.example/ - Match: http://web.archive.org/web/20010101000000/https://a.example/",
+			"[=] https://bücher.example/ - Match: http://web.archive.org/web/20020101000000/https://xn--bcher-kva.example/",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := runTestCheck(t, server, append(tt.args, "-verify-map", mapping))
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestCheckVerifyMapStructured(t *testing.T) {
+	server := newCDXServer(t, map[string][][]string{
+		"https://a.example/": {capture("https://a.example/", "20010101000000", "text/html", "A")},
+		"https://b.example/": {capture("https://b.example/", "20020101000000", "text/html", "B")},
+		"https://c.example/": {capture("https://c.example/", "20030101000000", "text/html", "C")},
+	})
+	dir := t.TempDir()
+	mapping := filepath.Join(dir, "mapping.csv")
+	err := os.WriteFile(mapping, []byte(strings.Join([]string{
+		"https://a.example/, https://web.archive.org/web/20010101000000/https://a.example/",
+		"https://b.example/, https://web.archive.org/web/19990101000000/https://b.example/",
+		"https://missing.example/, https://web.archive.org/web/20050101000000/https://missing.example/",
+	}, "\n")), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	csvFile := filepath.Join(dir, "snapshots.csv")
+
+	// c.example is checked but not listed in the mapping.
+	lines := runTestCheck(t, server, []string{"-verify-map", mapping, "-jsonl", "-csv", csvFile}, "https://c.example/")
+	type verified struct {
+		Expected string `json:"expected_archive_url"`
+		Match    *bool  `json:"match"`
+	}
+	yes, no := true, false
+	want := map[string]verified{
+		"https://a.example/":       {"https://web.archive.org/web/20010101000000/https://a.example/", &yes},
+		"https://b.example/":       {"https://web.archive.org/web/19990101000000/https://b.example/", &no},
+		"https://missing.example/": {"https://web.archive.org/web/20050101000000/https://missing.example/", &no},
+		"https://c.example/":       {},
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("printed %d lines, want %d:\n%s", len(lines), len(want), strings.Join(lines, "\n"))
+	}
+	for _, line := range lines {
+		var got struct {
+			URL string `json:"url"`
+			verified
+		}
+		if err := json.Unmarshal([]byte(line), &got); err != nil {
+			t.Fatal(err)
+		}
+		w := want[got.URL]
+		if got.Expected != w.Expected || (got.Match == nil) != (w.Match == nil) || got.Match != nil && *got.Match != *w.Match {
+			t.Errorf("got %s, want expected_archive_url %q and match %v", line, w.Expected, w.Match)
+		}
+	}
+
+	data, err := os.ReadFile(csvFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := strings.Split(strings.TrimSpace(string(data)), "\n")
+	slices.Sort(rows[1:])
+	wantRows := []string{
+		"url,urlkey,timestamp,original,mimetype,statuscode,digest,length,expected_archive_url,match",
+		"https://a.example/,key,20010101000000,https://a.example/,text/html,200,A,100,https://web.archive.org/web/20010101000000/https://a.example/,true",
+		"https://b.example/,key,20020101000000,https://b.example/,text/html,200,B,100,https://web.archive.org/web/19990101000000/https://b.example/,false",
+		"https://c.example/,key,20030101000000,https://c.example/,text/html,200,C,100,,",
+		"https://missing.example/,,,,,,,,https://web.archive.org/web/20050101000000/https://missing.example/,false",
+	}
+	if !slices.Equal(rows, wantRows) {
+		t.Errorf("got CSV\n%s\nwant\n%s", strings.Join(rows, "\n"), strings.Join(wantRows, "\n"))
+	}
+}
+
+func TestCheckDetails(t *testing.T) {
+	server := newCDXServer(t, map[string][][]string{
+		"https://a.example/page?q=1": {
+			capture("https://a.example/page?q=1", "20010101000000", "text/html", "A"),
+			capture("https://a.example/page?q=1", "20150101000000", "text/html", "B"),
+		},
+	})
+	tests := []struct {
+		name string
+		args []string
+		want string // Details link expected in the output; "" if none
+	}{
+		{"without -details", nil, ""},
+		{"calendar around the oldest capture", []string{"-details"}, "http://web.archive.org/web/20010101000000*/https://a.example/page?q=1"},
+		{"calendar around the latest capture", []string{"-details", "-latest"}, "http://web.archive.org/web/20150101000000*/https://a.example/page?q=1"},
+		{"details_url in JSON output", []string{"-details", "-jsonl"}, `"details_url":"http://web.archive.org/web/20010101000000*/https://a.example/page?q=1"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := runTestCheck(t, server, tt.args, "https://a.example/page?q=1")
+			if len(lines) != 1 {
+				t.Fatalf("printed %d lines, want 1:\n%s", len(lines), strings.Join(lines, "\n"))
+			}
+			switch {
+			case tt.want == "" && strings.Contains(lines[0], "*/"):
+				t.Errorf("unexpected details link in %s", lines[0])
+			case tt.want != "" && !strings.Contains(lines[0], tt.want):
+				t.Errorf("got %s\nwant it to contain %s", lines[0], tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckChangedOnly(t *testing.T) {
+	captures := map[string][][]string{
+		"https://a.example/": {capture("https://a.example/", "20010101000000", "text/html", "A")},
+		"https://b.example/": {capture("https://b.example/", "20020101000000", "text/html", "B")},
+	}
+	server := newCDXServer(t, captures)
+	args := []string{"-state", filepath.Join(t.TempDir(), "state.json"), "-changed-only", "-latest"}
+	urls := []string{"https://a.example/", "https://b.example/", "https://missing.example/"}
+
+	runs := []struct {
+		name   string
+		update func() // Changes the archive before the run
+		want   []string
+	}{
+		{"first run prints every found URL", func() {}, []string{"https://a.example/", "https://b.example/"}},
+		{"unchanged URLs skipped", func() {}, nil},
+		{"only the URL with a newer capture printed", func() {
+			captures["https://a.example/"] = append(captures["https://a.example/"], capture("https://a.example/", "20200101000000", "text/html", "A2"))
+		}, []string{"https://a.example/"}},
+		{"older capture does not count as a change", func() {
+			captures["https://b.example/"] = append(captures["https://b.example/"], capture("https://b.example/", "19990101000000", "text/html", "B0"))
+		}, nil},
+		{"URL captured for the first time printed", func() {
+			captures["https://missing.example/"] = [][]string{capture("https://missing.example/", "20210101000000", "text/html", "M")}
+		}, []string{"https://missing.example/"}},
+	}
+	for _, run := range runs {
+		run.update()
+		var got []string
+		for _, line := range runTestCheck(t, server, args, urls...) {
+			got = append(got, strings.Fields(line)[1])
+		}
+		slices.Sort(got)
+		if !slices.Equal(got, run.want) {
+			t.Errorf("%s: printed %q, want %q", run.name, got, run.want)
+		}
+	}
+}
+
+func TestCheckAll(t *testing.T) {
+	server := newCDXServer(t, map[string][][]string{
+		"https://a.example/": {
+			capture("https://a.example/", "20010101000000", "text/html", "A"),
+			capture("https://a.example/", "20100101000000", "text/html", "B"),
+		},
+	})
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{"snapshots listed under the result", []string{"-all"}, []string{
+			"[+] https://a.example/ - Snapshots: 2 - Oldest: http://web.archive.org/web/20010101000000/https://a.example/",
+			"    20010101000000 http://web.archive.org/web/20010101000000/https://a.example/",
+			"    20100101000000 http://web.archive.org/web/20100101000000/https://a.example/",
+		}},
+		{"result alone without -all", nil, []string{
+			"[+] https://a.example/ - Snapshots: 2 - Oldest: http://web.archive.org/web/20010101000000/https://a.example/",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := runTestCheck(t, server, tt.args, "https://a.example/"); !slices.Equal(got, tt.want) {
+				t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestCheckResumeAppendsCSV(t *testing.T) {
+	server := newCDXServer(t, map[string][][]string{
+		"https://a.example/": {capture("https://a.example/", "20010101000000", "text/html", "A")},
+		"https://b.example/": {capture("https://b.example/", "20020101000000", "text/html", "B")},
+	})
+	dir := t.TempDir()
+	csvFile := filepath.Join(dir, "snapshots.csv")
+	resumeFile := filepath.Join(dir, "resume.txt")
+	args := []string{"-resume", resumeFile, "-csv", csvFile}
+
+	// The first run dies after a.example; the second skips it.
+	runTestCheck(t, server, args, "https://a.example/")
+	runTestCheck(t, server, args, "https://a.example/", "https://b.example/")
+
+	data, err := os.ReadFile(csvFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "url,") ||
+		!strings.Contains(lines[1], "a.example") || !strings.Contains(lines[2], "b.example") {
+		t.Errorf("got CSV\n%s\nwant the header once, then the rows of a.example and b.example", data)
+	}
+	if data, _ := os.ReadFile(resumeFile); string(data) != "https://a.example/\nhttps://b.example/\n" {
+		t.Errorf("got checkpoint %q", data)
+	}
+}
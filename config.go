@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// configPath returns the config file location: $TIMETRAVELLER_CONFIG, or
+// "timetraveller/config" in the user's config directory.
+func configPath() string {
+	if path := os.Getenv("TIMETRAVELLER_CONFIG"); path != "" {
+		return path
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "timetraveller", "config")
+}
+
+// parseFlags parses a command's arguments, then fills the flags not given on
+// the command line from the config file, then sets up color and any
+// -log-file.
+func parseFlags(fs *flag.FlagSet, args []string) {
+	fs.Parse(args)
+	if err := applyConfig(fs, configPath()); err != nil {
+		fatalf("Error reading config file: %v", err)
+	}
+	if err := setupColor(fs); err != nil {
+		usageFatalf("%v", err)
+	}
+	if err := checkGzipLevel(fs); err != nil {
+		usageFatalf("%v", err)
+	}
+	if err := setupLogging(fs); err != nil {
+		fatalf("Error setting up -log-file: %v", err)
+	}
+}
+
+// applyConfig sets flags of fs from a file of "name = value" lines, skipping
+// flags already passed and names the command does not define. Blank lines and
+// lines starting with '#' are ignored; repeatable flags may appear repeatedly.
+// A missing file is not an error.
+func applyConfig(fs *flag.FlagSet, path string) error {
+	if path == "" {
+		return nil
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	passed := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { passed[f.Name] = true })
+
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return fmt.Errorf("%s:%d: expected 'name = value'", path, lineNumber)
+		}
+		name = strings.TrimPrefix(strings.TrimSpace(name), "-")
+		if passed[name] || fs.Lookup(name) == nil {
+			continue
+		}
+		if err := fs.Set(name, strings.TrimSpace(value)); err != nil {
+			return fmt.Errorf("%s:%d: %v", path, lineNumber, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// expandHome replaces a leading "~/" in path with the user's home directory,
+// for paths given in the config file, where no shell expands it.
+func expandHome(path string) string {
+	rest, ok := strings.CutPrefix(path, "~/")
+	if !ok {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, rest)
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/aleister1102/timetraveller/pkg/timetraveller"
+)
+
+// runDiff prints a unified diff between two snapshots of the same URL.
+func runDiff(args []string) {
+	var f engineFlags
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	f.registerLookup(fs)
+	f.registerOutput(fs)
+	fromTimestamp := fs.String("a", "", "Timestamp of the first snapshot (closest capture is used; default oldest)")
+	toTimestamp := fs.String("b", "", "Timestamp of the second snapshot (closest capture is used; default latest)")
+	raw := fs.Bool("raw", true, "Compare the original bytes (id_ modifier) rather than the rewritten playback pages")
+	maxSize := fs.Int64("max-size", 10<<20, "Maximum snapshot size in bytes (0 = unlimited)")
+	contextLines := fs.Int("U", 3, "Lines of context around each change")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: timetraveller diff [options] <url>\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	parseFlags(fs, args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(exitUsage)
+	}
+	targetURL := fs.Arg(0)
+
+	opts, err := f.lookupOptions()
+	if err != nil {
+		usageFatalf("%v", err)
+	}
+	opts.Raw = *raw
+
+	ctx := context.Background()
+	client := f.client()
+	result := client.Lookup(ctx, targetURL, opts)
+	if result.Error != nil {
+		fatalf("Error looking up %s: %v", targetURL, result.Error)
+	}
+	if result.Status != timetraveller.StatusFound {
+		slog.Error(fmt.Sprintf("No snapshots found for %s", targetURL))
+		os.Exit(exitNotFound)
+	}
+
+	pick := func(timestamp string, latest bool) timetraveller.SnapshotEntry {
+		selectOpts := opts
+		selectOpts.Latest = latest
+		selectOpts.Closest = timestamp
+		return timetraveller.SelectSnapshot(result.Snapshots, selectOpts)
+	}
+	entryA, entryB := pick(*fromTimestamp, false), pick(*toTimestamp, true)
+
+	bodyA, err := client.Download(ctx, entryA, *maxSize, opts)
+	if err != nil {
+		fatalf("Error downloading %s: %v", entryA.ArchiveURL(), err)
+	}
+	bodyB, err := client.Download(ctx, entryB, *maxSize, opts)
+	if err != nil {
+		fatalf("Error downloading %s: %v", entryB.ArchiveURL(), err)
+	}
+
+	fmt.Printf("--- %s\n+++ %s\n", entryA.ArchiveURL(), entryB.ArchiveURL())
+	fmt.Print(unifiedDiff(diffLines(splitLines(string(bodyA)), splitLines(string(bodyB))), *contextLines))
+}
+
+// splitLines splits text into lines without their terminators.
+func splitLines(text string) []string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// diffOp is one line of an edit script: ' ' kept, '-' deleted or '+' inserted.
+type diffOp struct {
+	kind byte
+	line string
+}
+
+// diffLines computes a shortest edit script from a to b with Myers' algorithm.
+func diffLines(a, b []string) []diffOp {
+	// Common prefix and suffix never change; strip them to keep the search small.
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var ops []diffOp
+	for _, line := range a[:prefix] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	ops = append(ops, myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, line := range a[len(a)-suffix:] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	return ops
+}
+
+func myers(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+	offset := n + m + 1
+	v := make([]int, 2*offset+1)
+	// trace[d] holds v[-d-1 .. d+1] as it was before round d.
+	var trace [][]int
+
+search:
+	for d := 0; d <= n+m; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d-1:offset+d+2]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	var ops []diffOp
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		at := func(k int) int { return trace[d][k+d+1] }
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			ops = append(ops, diffOp{' ', a[x-1]})
+			x--
+			y--
+		}
+		if d == 0 {
+			break
+		}
+		if x == prevX {
+			ops = append(ops, diffOp{'+', b[y-1]})
+			y--
+		} else {
+			ops = append(ops, diffOp{'-', a[x-1]})
+			x--
+		}
+	}
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
+
+// unifiedDiff renders an edit script as unified diff hunks with the given
+// number of context lines. It returns "" when nothing changed.
+func unifiedDiff(ops []diffOp, context int) string {
+	var out strings.Builder
+	aLine, bLine := make([]int, len(ops)+1), make([]int, len(ops)+1)
+	for i, op := range ops {
+		aLine[i+1], bLine[i+1] = aLine[i], bLine[i]
+		if op.kind != '+' {
+			aLine[i+1]++
+		}
+		if op.kind != '-' {
+			bLine[i+1]++
+		}
+	}
+
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+		start := max(0, i-context)
+		end := i
+		// Extend the hunk while the next change is within reach of its context.
+		for j := i; j < len(ops) && j <= end+2*context+1; j++ {
+			if ops[j].kind != ' ' {
+				end = j
+			}
+		}
+		end = min(len(ops), end+context+1)
+
+		aCount, bCount := aLine[end]-aLine[start], bLine[end]-bLine[start]
+		aStart, bStart := aLine[start]+1, bLine[start]+1
+		if aCount == 0 {
+			aStart--
+		}
+		if bCount == 0 {
+			bStart--
+		}
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n", aStart, aCount, bStart, bCount)
+		for _, op := range ops[start:end] {
+			out.WriteByte(op.kind)
+			out.WriteString(op.line)
+			out.WriteByte('\n')
+		}
+		i = end
+	}
+	return out.String()
+}
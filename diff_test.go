@@ -0,0 +1,71 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	const ten = "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n"
+	tests := []struct {
+		name    string
+		a, b    string
+		context int
+		want    string
+	}{
+		{"identical", ten, ten, 3, ""},
+		{"one line changed", ten, "a\nb\nc\nd\nE\nf\ng\nh\ni\nj\n", 3, "@@ -2,7 +2,7 @@\n b\n c\n d\n-e\n+E\n f\n g\n h\n"},
+		{"lines added to an empty page", "", "x\ny\n", 3, "@@ -0,0 +1,2 @@\n+x\n+y\n"},
+		{"every line removed", "x\ny\n", "", 3, "@@ -1,2 +0,0 @@\n-x\n-y\n"},
+		{"distant changes in separate hunks", ten, "A\nb\nc\nd\ne\nf\ng\nh\ni\nJ\n", 1, "@@ -1,2 +1,2 @@\n-a\n+A\n b\n@@ -9,2 +9,2 @@\n i\n-j\n+J\n"},
+		{"close changes in one hunk", ten, "a\nB\nc\nd\nE\nf\ng\nh\ni\nj\n", 1, "@@ -1,6 +1,6 @@\n a\n-b\n+B\n c\n d\n-e\n+E\n f\n"},
+		{"CRLF line endings ignored", "a\r\nb\r\n", "a\nb\n", 3, ""},
+		{"no context", "a\nb\nc\n", "a\nx\nc\n", 0, "@@ -2,1 +2,1 @@\n-b\n+x\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := unifiedDiff(diffLines(splitLines(tt.a), splitLines(tt.b)), tt.context); got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffLinesShortestScript(t *testing.T) {
+	tests := []struct {
+		name      string
+		a, b      []string
+		wantEdits int
+	}{
+		{"empty", nil, nil, 0},
+		{"insert only", nil, []string{"x", "y"}, 2},
+		{"delete only", []string{"x", "y"}, nil, 2},
+		{"replace one", []string{"a", "b", "c"}, []string{"a", "x", "c"}, 2},
+		{"moved line", []string{"a", "b", "c", "d"}, []string{"b", "c", "d", "a"}, 2},
+		{"interleaved", []string{"a", "b", "c", "a", "b", "b", "a"}, []string{"c", "b", "a", "b", "a", "c"}, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ops := diffLines(tt.a, tt.b)
+			var fromA, toB []string
+			edits := 0
+			for _, op := range ops {
+				if op.kind != '+' {
+					fromA = append(fromA, op.line)
+				}
+				if op.kind != '-' {
+					toB = append(toB, op.line)
+				}
+				if op.kind != ' ' {
+					edits++
+				}
+			}
+			if !slices.Equal(fromA, tt.a) || !slices.Equal(toB, tt.b) {
+				t.Errorf("script %q does not turn %q into %q", ops, tt.a, tt.b)
+			}
+			if edits != tt.wantEdits {
+				t.Errorf("%d edits, want %d", edits, tt.wantEdits)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/aleister1102/timetraveller/pkg/timetraveller"
+)
+
+// sqliteHeader starts every SQLite database file.
+var sqliteHeader = []byte("SQLite format 3\x00")
+
+// previousResult is what an earlier run found for one URL.
+type previousResult struct {
+	status string
+	count  int
+}
+
+// previousRun holds the results of an earlier run by URL, for -diff-run.
+type previousRun map[string]previousResult
+
+// loadPreviousRun reads the results of an earlier run from a -json or -jsonl
+// output file, or the latest run recorded in a -db database.
+func loadPreviousRun(filename string) (previousRun, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	reader := bufio.NewReader(file)
+	if header, _ := reader.Peek(len(sqliteHeader)); bytes.Equal(header, sqliteHeader) {
+		return loadPreviousDB(filename)
+	}
+
+	// A -json array or a stream of -jsonl objects.
+	run := make(previousRun)
+	decoder := json.NewDecoder(reader)
+	if first, _ := reader.Peek(1); bytes.Equal(first, []byte("[")) {
+		var results []jsonResult
+		if err := decoder.Decode(&results); err != nil {
+			return nil, err
+		}
+		for _, r := range results {
+			run[r.URL] = previousResult{r.Status, r.SnapshotCount}
+		}
+		return run, nil
+	}
+	for {
+		var r jsonResult
+		if err := decoder.Decode(&r); err == io.EOF {
+			return run, nil
+		} else if err != nil {
+			return nil, err
+		}
+		run[r.URL] = previousResult{r.Status, r.SnapshotCount}
+	}
+}
+
+// loadPreviousDB reads the results of the latest run recorded in a -db database.
+func loadPreviousDB(filename string) (previousRun, error) {
+	db, err := sql.Open("sqlite", filename)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	rows, err := db.Query("SELECT url, status, snapshot_count FROM results WHERE run_id = (SELECT MAX(run_id) FROM results)")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	run := make(previousRun)
+	for rows.Next() {
+		var url string
+		var r previousResult
+		if err := rows.Scan(&url, &r.status, &r.count); err != nil {
+			return nil, err
+		}
+		run[url] = r
+	}
+	return run, rows.Err()
+}
+
+// change describes how r differs from the earlier run, or returns "" if it
+// does not. Failed lookups are never reported as changes.
+func (p previousRun) change(r timetraveller.ProcessResult) string {
+	if r.Error != nil {
+		return ""
+	}
+	found := r.Status == timetraveller.StatusFound
+	prev, known := p[r.URL]
+	switch {
+	case !known:
+		if found {
+			return "new"
+		}
+		return ""
+	case found && prev.status != timetraveller.StatusFound:
+		return "now found"
+	case !found && prev.status == timetraveller.StatusFound:
+		return "no longer found"
+	case found && r.SnapshotCount != prev.count:
+		return fmt.Sprintf("snapshots %+d (was %d)", r.SnapshotCount-prev.count, prev.count)
+	}
+	return ""
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/aleister1102/timetraveller/pkg/timetraveller"
+)
+
+// shutdown stops a run gracefully on SIGINT or SIGTERM: the first signal stops
+// dispatching new work, and in-flight requests get the drain timeout (or a
+// second signal) to finish before they are cancelled. Once the maximum
+// runtime (if any) is exceeded, the run is cancelled outright.
+type shutdown struct {
+	dispatch    context.Context // Done once no new work should start
+	requests    context.Context // Done once in-flight requests must abort
+	interrupted atomic.Bool
+	signals     chan os.Signal
+
+	pauseMu sync.Mutex
+	resumed chan struct{} // Closed on resume; nil while dispatching runs
+}
+
+func newShutdown(drainTimeout, maxRuntime time.Duration) *shutdown {
+	dispatchCtx, stopDispatch := context.WithCancel(context.Background())
+	requestCtx, cancelRequests := context.WithCancel(context.Background())
+	s := &shutdown{dispatch: dispatchCtx, requests: requestCtx}
+
+	var deadline <-chan time.Time
+	if maxRuntime > 0 {
+		deadline = time.After(maxRuntime)
+	}
+	sigChan := make(chan os.Signal, 1)
+	s.signals = sigChan
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		defer signal.Stop(sigChan)
+		select {
+		case sig := <-sigChan:
+			s.interrupted.Store(true)
+			slog.Warn("Received signal, waiting for in-flight requests (interrupt again to abort)", "signal", sig.String(), "timeout", drainTimeout)
+			stopDispatch()
+			select {
+			case <-sigChan:
+			case <-deadline:
+			case <-time.After(drainTimeout):
+			}
+		case <-deadline:
+			s.interrupted.Store(true)
+			slog.Warn("Maximum runtime reached, cancelling the run", "max_runtime", maxRuntime)
+			stopDispatch()
+		}
+		cancelRequests()
+	}()
+	return s
+}
+
+// interrupt stops the run as SIGINT would, for interactive front ends that
+// read the terminal in raw mode.
+func (s *shutdown) interrupt() {
+	select {
+	case s.signals <- os.Interrupt:
+	default:
+	}
+}
+
+// setPaused holds dispatching of new work back, or lets it continue.
+// In-flight requests are not affected.
+func (s *shutdown) setPaused(paused bool) {
+	s.pauseMu.Lock()
+	defer s.pauseMu.Unlock()
+	switch {
+	case paused && s.resumed == nil:
+		s.resumed = make(chan struct{})
+	case !paused && s.resumed != nil:
+		close(s.resumed)
+		s.resumed = nil
+	}
+}
+
+// waitResumed blocks while dispatching is paused and reports whether new
+// work may still start.
+func (s *shutdown) waitResumed() bool {
+	s.pauseMu.Lock()
+	resumed := s.resumed
+	s.pauseMu.Unlock()
+	if resumed != nil {
+		select {
+		case <-resumed:
+		case <-s.dispatch.Done():
+		}
+	}
+	return s.dispatch.Err() == nil
+}
+
+// shutdown returns the run's shutdown handler. Every call returns the same
+// handler, so all stages of a command stop together.
+func (f *engineFlags) shutdown() *shutdown {
+	f.shutdownOnce.Do(func() {
+		f.sharedShutdown = newShutdown(time.Duration(f.drainTimeoutMs)*time.Millisecond, f.maxRuntime)
+	})
+	return f.sharedShutdown
+}
+
+// interrupted reports whether the run was stopped early, by a signal or
+// -max-runtime.
+func (f *engineFlags) interrupted() bool {
+	return f.sharedShutdown != nil && f.sharedShutdown.interrupted.Load()
+}
+
+// reportInterrupted prints what an interrupted run got through, so partial
+// output is not mistaken for a complete run.
+func (f *engineFlags) reportInterrupted(format string, args ...any) {
+	if !f.interrupted() {
+		return
+	}
+	slog.Warn("Stopped early: " + fmt.Sprintf(format, args...))
+}
+
+// startLookups looks up every URL received on urls on a pool of workers and
+// returns the channel their results arrive on; it is closed once urls is
+// closed and all workers have finished. Input is consumed as it arrives and
+// results must be drained as they come, so memory stays bounded however long
+// the input is. On interrupt, dispatching stops and in-flight requests get
+// the drain timeout to finish before they are cancelled.
+func startLookups(f *engineFlags, urls <-chan string, opts timetraveller.Options) <-chan timetraveller.ProcessResult {
+	if f.batchHosts > 0 {
+		return startBatchedLookups(f, urls, opts)
+	}
+	stop := f.shutdown()
+	jobs := make(chan string)
+	client := f.client()
+	results := client.LookupAll(stop.requests, jobs, f.numWorkers, time.Duration(f.delayMs)*time.Millisecond, opts)
+
+	// Send jobs until the input ends or an interrupt stops dispatching
+	go func() {
+		defer close(jobs)
+		for u := range urls {
+			if !stop.waitResumed() {
+				return
+			}
+			select {
+			case jobs <- u:
+				if f.dispatched != nil {
+					f.dispatched(u)
+				}
+			case <-stop.dispatch.Done():
+				return
+			}
+		}
+	}()
+	return f.metrics.count(results)
+}
+
+// startBatchedLookups is startLookups for -batch-hosts: it reads the whole
+// input first, so the URLs of each host can be looked up with one host-wide
+// query.
+func startBatchedLookups(f *engineFlags, urls <-chan string, opts timetraveller.Options) <-chan timetraveller.ProcessResult {
+	stop := f.shutdown()
+	batches := make(chan []string)
+	client := f.client()
+	results := client.LookupBatches(stop.requests, batches, f.numWorkers, time.Duration(f.delayMs)*time.Millisecond, opts)
+
+	go func() {
+		defer close(batches)
+		var all []string
+	read:
+		for {
+			select {
+			case u, ok := <-urls:
+				if !ok {
+					break read
+				}
+				all = append(all, u)
+			case <-stop.dispatch.Done():
+				return
+			}
+		}
+		for _, batch := range timetraveller.GroupByHost(all, f.batchHosts, opts) {
+			if !stop.waitResumed() {
+				return
+			}
+			select {
+			case batches <- batch:
+				if f.dispatched != nil {
+					for _, u := range batch {
+						f.dispatched(u)
+					}
+				}
+			case <-stop.dispatch.Done():
+				return
+			}
+		}
+	}()
+	return f.metrics.count(results)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aleister1102/timetraveller/pkg/timetraveller"
+)
+
+func TestStartLookupsDrainsInFlightOnInterrupt(t *testing.T) {
+	tests := []struct {
+		name       string
+		drain      time.Duration
+		delay      time.Duration // How long the archive takes to answer
+		wantStatus string
+	}{
+		{"in-flight lookup finishes within the drain window", 2 * time.Second, 100 * time.Millisecond, timetraveller.StatusFound},
+		{"in-flight lookup cancelled after the drain window", 50 * time.Millisecond, 2 * time.Second, timetraveller.StatusError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			started := make(chan struct{}, 1)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				started <- struct{}{}
+				select {
+				case <-time.After(tt.delay):
+				case <-r.Context().Done():
+					return
+				}
+				json.NewEncoder(w).Encode([][]string{cdxHeader, capture(r.URL.Query().Get("url"), "20010101000000", "text/html", "A")})
+			}))
+			defer server.Close()
+
+			var f engineFlags
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			f.register(fs)
+			if err := fs.Parse([]string{"-retries", "0", "-t", "1", "-cdx-url", server.URL,
+				"-drain-timeout", strconv.FormatInt(tt.drain.Milliseconds(), 10)}); err != nil {
+				t.Fatal(err)
+			}
+			opts, err := f.lookupOptions()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			urls := make(chan string)
+			results := startLookups(&f, urls, opts)
+			urls <- "https://a.example/"
+			<-started
+			f.shutdown().interrupt()
+			// Dispatching stopped: this URL is never looked up.
+			go func() {
+				urls <- "https://b.example/"
+				close(urls)
+			}()
+
+			var got []timetraveller.ProcessResult
+			timeout := time.After(tt.drain + time.Second)
+			for results != nil {
+				select {
+				case result, ok := <-results:
+					if !ok {
+						results = nil
+						continue
+					}
+					got = append(got, result)
+				case <-timeout:
+					t.Fatal("results not flushed within the drain window")
+				}
+			}
+			if len(got) != 1 || got[0].URL != "https://a.example/" || got[0].Status != tt.wantStatus {
+				t.Fatalf("got results %+v, want one %q result for https://a.example/", got, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aleister1102/timetraveller/pkg/timetraveller"
+)
+
+const (
+	// esTimeout bounds one bulk request of the Elasticsearch sink.
+	esTimeout = time.Minute
+	// esRetries is how many times a bulk request failing with a network
+	// error, 429 or 5xx is retried, after esRetryDelay, doubled each time.
+	esRetries    = 3
+	esRetryDelay = time.Second
+)
+
+// esSink bulk-indexes results into an Elasticsearch or OpenSearch index: one
+// "result" document per result and, with -all, one "snapshot" document per
+// capture. Snapshot documents have IDs derived from the capture, so runs
+// looking up the same URLs update them instead of adding duplicates.
+type esSink struct {
+	endpoint     string // The _bulk URL, without credentials
+	user, pass   string
+	apiKey       string
+	index        string
+	batch        int // Documents queued before a bulk request
+	all, changes bool
+	started      time.Time
+	client       *http.Client
+	pending      bytes.Buffer
+	count        int // Documents in pending
+}
+
+// esResult is the document indexed for a result.
+type esResult struct {
+	jsonResult
+	DocType   string    `json:"doc_type"`
+	Timestamp time.Time `json:"@timestamp"` // When the run started
+}
+
+// esSnapshot is the document indexed for a capture in -all mode.
+type esSnapshot struct {
+	DocType    string     `json:"doc_type"`
+	URL        string     `json:"url"`
+	Timestamp  string     `json:"timestamp"`
+	CapturedAt *time.Time `json:"captured_at,omitempty"`
+	Original   string     `json:"original"`
+	Mimetype   string     `json:"mimetype,omitempty"`
+	StatusCode string     `json:"statuscode,omitempty"`
+	Digest     string     `json:"digest,omitempty"`
+	Length     string     `json:"length,omitempty"`
+	ArchiveURL string     `json:"archive_url"`
+	Archive    string     `json:"archive"`
+}
+
+// newESSink returns a sink indexing into the -es-index index of the cluster
+// at target, which may carry basic auth credentials.
+func (f *checkFlags) newESSink(target string) (*esSink, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+	s := &esSink{index: f.esIndex, apiKey: f.esAPIKey, batch: f.esBatch, all: f.allSnapshots, changes: f.changes,
+		started: time.Now().UTC(), client: &http.Client{Timeout: esTimeout}}
+	if u.User != nil {
+		s.user = u.User.Username()
+		s.pass, _ = u.User.Password()
+		u.User = nil
+	}
+	s.endpoint = strings.TrimSuffix(u.String(), "/") + "/_bulk"
+	return s, nil
+}
+
+// validateESURL rejects Elasticsearch targets that are not http(s) URLs of a
+// cluster.
+func validateESURL(target string) error {
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("expected the http(s) URL of the cluster, e.g. http://localhost:9200")
+	}
+	return nil
+}
+
+func (s *esSink) Write(r checkResult) error {
+	s.add("", esResult{jsonResult: r.json(false, s.changes), DocType: "result", Timestamp: s.started})
+	if s.all {
+		for _, entry := range r.Snapshots {
+			doc := esSnapshot{
+				DocType:    "snapshot",
+				URL:        r.URL,
+				Timestamp:  entry.Field(timetraveller.FieldTimestamp),
+				Original:   entry.Field(timetraveller.FieldOriginal),
+				Mimetype:   entry.Field(timetraveller.FieldMimetype),
+				StatusCode: entry.Field(timetraveller.FieldStatusCode),
+				Digest:     entry.Field(timetraveller.FieldDigest),
+				Length:     entry.Field(timetraveller.FieldLength),
+				ArchiveURL: entry.ArchiveURL(),
+				Archive:    entry.Archive(),
+			}
+			if captured, err := timetraveller.ParseTimestamp(doc.Timestamp); err == nil {
+				doc.CapturedAt = &captured
+			}
+			id := sha256.Sum256([]byte(doc.URL + "\x00" + doc.ArchiveURL))
+			s.add(hex.EncodeToString(id[:]), doc)
+		}
+	}
+	if s.count >= s.batch {
+		return s.Flush()
+	}
+	return nil
+}
+
+// add queues the bulk action indexing doc, under id unless it is empty.
+func (s *esSink) add(id string, doc any) {
+	action := map[string]map[string]string{"index": {"_index": s.index}}
+	if id != "" {
+		action["index"]["_id"] = id
+	}
+	enc := json.NewEncoder(&s.pending)
+	enc.Encode(action)
+	enc.Encode(doc)
+	s.count++
+}
+
+// Flush sends the queued documents in one bulk request.
+func (s *esSink) Flush() error {
+	if s.count == 0 {
+		return nil
+	}
+	body := s.pending.Bytes()
+	delay := esRetryDelay
+	for attempt := 0; ; attempt++ {
+		retry, err := s.send(body)
+		if err == nil {
+			break
+		}
+		if !retry || attempt >= esRetries {
+			return err
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+	s.pending.Reset()
+	s.count = 0
+	return nil
+}
+
+func (s *esSink) Close() error { return s.Flush() }
+
+// send makes one bulk request, reporting whether a failure is worth
+// retrying. Documents the cluster rejects fail the request as a whole.
+func (s *esSink) send(body []byte) (retry bool, err error) {
+	req, err := http.NewRequest("POST", s.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	switch {
+	case s.apiKey != "":
+		req.Header.Set("Authorization", "ApiKey "+s.apiKey)
+	case s.user != "":
+		req.SetBasicAuth(s.user, s.pass)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		retry = resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		return retry, fmt.Errorf("bulk request answered %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+
+	var bulk struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			Status int             `json:"status"`
+			Error  json.RawMessage `json:"error"`
+		} `json:"items"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&bulk); err != nil {
+		return false, fmt.Errorf("reading bulk response: %w", err)
+	}
+	if !bulk.Errors {
+		return false, nil
+	}
+	for _, item := range bulk.Items {
+		for _, result := range item {
+			if result.Error != nil {
+				// Retrying would index the accepted documents again.
+				return false, fmt.Errorf("document rejected with status %d: %s", result.Status, result.Error)
+			}
+		}
+	}
+	return false, errors.New("bulk request reported errors")
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+
+	"github.com/aleister1102/timetraveller/pkg/timetraveller"
+)
+
+// endpointPattern finds quoted URLs and paths in JavaScript, after LinkFinder:
+// absolute and protocol-relative URLs, rooted or relative paths, and file
+// names with common server-side extensions.
+var endpointPattern = regexp.MustCompile(`(?:"|'|` + "`" + `)(` +
+	`(?:[a-zA-Z]{1,10}://|//)[^"'/]+\.[a-zA-Z]{2,}[^"']*` +
+	`|(?:/|\.\./|\./)[^"'><,;| *()%$^/\\\[\]][^"'><,;|()]+` +
+	`|[a-zA-Z0-9_\-/]+/[a-zA-Z0-9_\-/]+\.(?:[a-zA-Z]{1,4}|action)(?:[?#][^"']*)?` +
+	`|[a-zA-Z0-9_\-/]+/[a-zA-Z0-9_\-/]{3,}(?:[?#][^"']*)?` +
+	`|[a-zA-Z0-9_\-]+\.(?:php|asp|aspx|jsp|json|action|html|js|txt|xml)(?:[?#][^"']*)?` +
+	`)(?:"|'|` + "`" + `)`)
+
+// extractEndpoints returns the endpoints referenced in a JavaScript body.
+func extractEndpoints(body []byte) []string {
+	var endpoints []string
+	for _, match := range endpointPattern.FindAllSubmatch(body, -1) {
+		endpoints = append(endpoints, string(match[1]))
+	}
+	return endpoints
+}
+
+// runEndpoints downloads archived JavaScript files of each domain and prints
+// the endpoints they reference, deduplicated per domain.
+func runEndpoints(args []string) {
+	var f engineFlags
+	fs := flag.NewFlagSet("endpoints", flag.ExitOnError)
+	f.register(fs)
+	outputFile := fs.String("o", "", "File to write extracted endpoints to")
+	noSubs := fs.Bool("no-subs", false, "Only use JavaScript files on the given host, not its subdomains")
+	downloadWorkers := fs.Int("dt", 5, "Number of concurrent downloads")
+	maxSize := fs.Int64("max-size", 5<<20, "Maximum JavaScript file size in bytes (0 = unlimited)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: timetraveller endpoints [options] <domain1> [domain2 ...]\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	parseFlags(fs, args)
+
+	domains, inputStats, err := f.readInput(fs.Args())
+	if err != nil {
+		usageFatalf("%v", err)
+	}
+	inputStats.report()
+	if len(domains) == 0 {
+		fs.Usage()
+		os.Exit(exitUsage)
+	}
+
+	opts, err := f.lookupOptions()
+	if err != nil {
+		usageFatalf("%v", err)
+	}
+	opts.MatchType = timetraveller.MatchDomain
+	if *noSubs {
+		opts.MatchType = timetraveller.MatchHost
+	}
+	// One capture per unique .js URL, downloaded as originally served.
+	opts.Filters = append(opts.Filters, "statuscode:200", `original:.*\.js(\?.*)?$`)
+	opts.Collapse = append(opts.Collapse, "urlkey")
+	opts.Raw = true
+
+	pickAll := func(result timetraveller.ProcessResult) []timetraveller.SnapshotEntry {
+		return result.Snapshots
+	}
+	seen := make(map[string]map[string]bool)
+	var endpoints []string
+	downloads := startDownloads(&f, sendURLs(domains), opts, downloadConfig{
+		workers: *downloadWorkers,
+		maxSize: *maxSize,
+		pick:    pickAll,
+	})
+	for d := range downloads {
+		if d.error != nil {
+			slog.Error("Download failed", "url", d.job.entry.ArchiveURL(), "error", d.error)
+			continue
+		}
+		if seen[d.job.inputURL] == nil {
+			seen[d.job.inputURL] = make(map[string]bool)
+		}
+		for _, endpoint := range extractEndpoints(d.body) {
+			if seen[d.job.inputURL][endpoint] {
+				continue
+			}
+			seen[d.job.inputURL][endpoint] = true
+			endpoints = append(endpoints, endpoint)
+			fmt.Printf("%s %s\n", d.job.inputURL, endpoint)
+		}
+	}
+
+	if *outputFile != "" && len(endpoints) > 0 {
+		if err := writeUrlsToFile(*outputFile, endpoints, f.gzipLevel); err != nil {
+			fatalf("Error writing to output file: %v", err)
+		}
+	}
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/aleister1102/timetraveller/pkg/timetraveller"
+)
+
+// downloadJob is a snapshot selected for download.
+type downloadJob struct {
+	inputURL string
+	entry    timetraveller.SnapshotEntry
+}
+
+// downloadResult is the outcome of a downloadJob.
+type downloadResult struct {
+	job   downloadJob
+	path  string
+	body  []byte
+	error error
+	note  string // Extra report from downloadConfig.after, if any
+}
+
+// downloadConfig controls startDownloads.
+type downloadConfig struct {
+	workers int
+	dir     string // Directory to save bodies below; "" keeps them in memory only
+	maxSize int64
+	// pick selects the snapshots of a found result to download.
+	pick func(timetraveller.ProcessResult) []timetraveller.SnapshotEntry
+	// after, if set, runs on each successful download inside the worker.
+	after func(*downloadResult)
+}
+
+// runFetch looks up snapshots and saves their archived bodies to disk.
+func runFetch(args []string) {
+	var f engineFlags
+	fs := flag.NewFlagSet("fetch", flag.ExitOnError)
+	f.register(fs)
+	dir := fs.String("dir", "snapshots", "Directory to save snapshots to (empty to skip saving files)")
+	warcFile := fs.String("warc", "", "WARC file to package downloaded snapshots into (.warc or .warc.gz); implies -raw")
+	latest := fs.Bool("latest", false, "Download the latest snapshot instead of the oldest")
+	closest := fs.String("closest", "", "Download the snapshot closest to this timestamp")
+	all := fs.Bool("all", false, "Download every snapshot instead of only the oldest or latest")
+	downloadWorkers := fs.Int("dt", 5, "Number of concurrent downloads")
+	raw := fs.Bool("raw", false, "Download the original bytes (id_ modifier) without Wayback rewriting or toolbar")
+	grepPattern := fs.String("grep", "", "Regular expression to search downloaded snapshots for; prints each match with its line and offset")
+	secrets := fs.Bool("secrets", false, "Scan downloaded snapshots for API keys, tokens and other credentials")
+	compareLive := fs.Bool("compare-live", false, "Compare each snapshot with the live page: status, size delta, hash match and similarity")
+	maxSize := fs.Int64("max-size", 10<<20, "Maximum snapshot size in bytes (0 = unlimited)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: timetraveller fetch [options] <url1> [url2 ...]\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	parseFlags(fs, args)
+
+	if fs.NArg() == 0 && !stdinPiped() {
+		fs.Usage()
+		os.Exit(exitUsage)
+	}
+
+	var rules []scanRule
+	if *grepPattern != "" {
+		pattern, err := regexp.Compile(*grepPattern)
+		if err != nil {
+			usageFatalf("Invalid -grep pattern: %v", err)
+		}
+		rules = append(rules, scanRule{name: "grep", pattern: pattern})
+	}
+	if *secrets {
+		rules = append(rules, secretRules...)
+	}
+	// When scanning or comparing, only keep files on disk if -dir was given explicitly.
+	if (len(rules) > 0 || *compareLive) && !flagPassed(fs, "dir") {
+		*dir = ""
+	}
+
+	opts, err := f.lookupOptions()
+	if err != nil {
+		usageFatalf("%v", err)
+	}
+	opts.Latest = *latest
+	opts.Closest = *closest
+	// WARC response records hold the bytes the site served, never the
+	// archive's rewritten playback page.
+	opts.Raw = *raw || *warcFile != ""
+
+	var warc *warcWriter
+	if *warcFile != "" {
+		if warc, err = newWARCWriter(*warcFile); err != nil {
+			fatalf("Error creating WARC file: %v", err)
+		}
+		defer warc.Close()
+	}
+
+	pick := func(result timetraveller.ProcessResult) []timetraveller.SnapshotEntry {
+		if *all {
+			return result.Snapshots
+		}
+		return []timetraveller.SnapshotEntry{result.Chosen}
+	}
+	cfg := downloadConfig{
+		workers: *downloadWorkers,
+		dir:     *dir,
+		maxSize: *maxSize,
+		pick:    pick,
+	}
+	if *compareLive {
+		// The -H headers are meant for the archive, not the live sites.
+		liveClient, limiter := f.httpClient(nil), f.client().Limiter
+		cfg.after = func(d *downloadResult) {
+			d.note = compareWithLive(liveClient, limiter, d.job.entry.Field(timetraveller.FieldOriginal), d.body, *maxSize)
+		}
+	}
+	input, err := f.inputURLs(fs.Args())
+	if err != nil {
+		usageFatalf("%v", err)
+	}
+	urls, inputStats := f.prepareInput(input)
+	downloads := startDownloads(&f, urls, opts, cfg)
+
+	var downloaded, failed int
+	for d := range downloads {
+		if d.error == nil && warc != nil {
+			d.error = warc.writeSnapshot(d.job.entry, d.body)
+		}
+		if d.error != nil {
+			failed++
+			fmt.Printf(ColorRed+"[!] %s - %s - %v"+ColorReset+"\n", d.job.inputURL, d.job.entry.ArchiveURL(), d.error)
+			continue
+		}
+		downloaded++
+		if d.note != "" {
+			fmt.Printf(ColorGreen+"[+] %s - %s - %s"+ColorReset+"\n",
+				d.job.inputURL, d.job.entry.Field(timetraveller.FieldTimestamp), d.note)
+		}
+		if len(rules) > 0 {
+			timestamp := d.job.entry.Field(timetraveller.FieldTimestamp)
+			for _, hit := range scanBody(d.body, rules) {
+				label := ""
+				if hit.rule != "grep" {
+					label = hit.rule + " - "
+				}
+				fmt.Printf(ColorGreen+"[+] %s - %s - %sline %d offset %d: %s"+ColorReset+"\n",
+					d.job.inputURL, timestamp, label, hit.line, hit.offset, hit.match)
+			}
+			continue
+		}
+		if d.note != "" && d.path == "" {
+			continue
+		}
+		saved := d.path
+		if saved == "" {
+			saved = *warcFile
+		}
+		fmt.Printf(ColorGreen+"[+] %s - Saved: %s (%d bytes)"+ColorReset+"\n", d.job.inputURL, saved, len(d.body))
+	}
+	inputStats.report()
+	f.reportInterrupted("%d snapshots downloaded, %d failed", downloaded, failed)
+}
+
+// startDownloads looks up urls and downloads the snapshots selected by
+// cfg.pick on a pool of workers. Lookup failures are reported on stderr.
+func startDownloads(f *engineFlags, urls <-chan string, opts timetraveller.Options, cfg downloadConfig) <-chan downloadResult {
+	client := f.client()
+	stop := f.shutdown()
+	jobs := make(chan downloadJob)
+	downloads := make(chan downloadResult)
+	var wg sync.WaitGroup
+	for i := 0; i < cfg.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				result := saveSnapshot(stop.requests, client, job, cfg.dir, cfg.maxSize, opts)
+				if result.error == nil && cfg.after != nil {
+					cfg.after(&result)
+				}
+				downloads <- result
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(downloads)
+	}()
+
+	// Feed found snapshots to the downloaders, reporting lookup failures
+	// directly. Once interrupted, lookups still finishing are not downloaded.
+	go func() {
+		defer close(jobs)
+		for result := range startLookups(f, urls, opts) {
+			if stop.dispatch.Err() != nil {
+				continue
+			}
+			switch {
+			case result.Error != nil:
+				slog.Error("Lookup failed", "url", result.URL, "error", result.Error)
+			case result.Status != timetraveller.StatusFound:
+				fmt.Fprintf(os.Stderr, ColorYellow+"[-] %s\n"+ColorReset, result.URL)
+			default:
+				for _, entry := range cfg.pick(result) {
+					select {
+					case jobs <- downloadJob{inputURL: result.URL, entry: entry}:
+					case <-stop.dispatch.Done():
+					}
+				}
+			}
+		}
+	}()
+
+	return downloads
+}
+
+// saveSnapshot downloads one snapshot and writes it below dir.
+func saveSnapshot(ctx context.Context, client *timetraveller.Client, job downloadJob, dir string, maxSize int64, opts timetraveller.Options) downloadResult {
+	result := downloadResult{job: job}
+	body, err := client.Download(ctx, job.entry, maxSize, opts)
+	if err != nil {
+		result.error = err
+		return result
+	}
+	result.body = body
+	if dir == "" {
+		return result
+	}
+	path, err := snapshotPath(dir, job.entry)
+	if err != nil {
+		result.error = err
+		return result
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		result.error = err
+		return result
+	}
+	if err := os.WriteFile(path, body, 0o644); err != nil {
+		result.error = err
+		return result
+	}
+	result.path = path
+	return result
+}
+
+var unsafeFilenameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// snapshotPath returns where a snapshot is saved below dir:
+// "<host>/<timestamp>_<path>", each part flattened into a filesystem-safe
+// name. Snapshots whose names would resolve outside dir are rejected.
+func snapshotPath(dir string, entry timetraveller.SnapshotEntry) (string, error) {
+	original := entry.Field(timetraveller.FieldOriginal)
+	host := safeFilename(hostOf(original))
+	if host == "" {
+		host = "unknown"
+	}
+	timestamp := safeFilename(entry.Field(timetraveller.FieldTimestamp))
+	if host == "." || host == ".." || timestamp == "." || timestamp == ".." {
+		return "", fmt.Errorf("unsafe file name for snapshot of %s at %q", original, entry.Field(timetraveller.FieldTimestamp))
+	}
+	rest := original
+	if i := strings.Index(rest, "://"); i >= 0 {
+		rest = rest[i+3:]
+	}
+	if i := strings.IndexAny(rest, "/?"); i >= 0 {
+		rest = rest[i:]
+	} else {
+		rest = ""
+	}
+	name := safeFilename(rest)
+	if name == "" {
+		name = "index"
+	}
+	if len(name) > 150 {
+		name = name[:150]
+	}
+	path := filepath.Join(dir, host, timestamp+"_"+name)
+	if rel, err := filepath.Rel(dir, path); err != nil || !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("snapshot of %s would be saved outside %s", original, dir)
+	}
+	return path, nil
+}
+
+// safeFilename replaces the runs of characters of s that are unsafe in file
+// names with underscores, trimming them at both ends.
+func safeFilename(s string) string {
+	return strings.Trim(unsafeFilenameChars.ReplaceAllString(s, "_"), "_")
+}
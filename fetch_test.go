@@ -0,0 +1,46 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/aleister1102/timetraveller/pkg/timetraveller"
+)
+
+func TestSnapshotPath(t *testing.T) {
+	dir := filepath.Join("out", "snapshots")
+	tests := []struct {
+		name      string
+		timestamp string
+		original  string
+		want      string // Path below dir; "" if rejected
+	}{
+		{"page", "20010101000000", "https://Example.com/a/b.html", "example.com/20010101000000_a_b.html"},
+		{"root", "20010101000000", "https://example.com/", "example.com/20010101000000_index"},
+		{"query flattened", "20010101000000", "https://example.com/search?q=a b&x=../y", "example.com/20010101000000_search_q_a_b_x_.._y"},
+		{"traversal in the path flattened", "20010101000000", "https://example.com/../../etc/passwd", "example.com/20010101000000_.._.._etc_passwd"},
+		{"missing host", "20010101000000", "", "unknown/20010101000000_index"},
+		{"unsafe timestamp flattened", "2001/../../x", "https://example.com/", "example.com/2001_.._.._x_index"},
+		{"dot-dot host rejected", "20010101000000", "http://../etc/passwd", ""},
+		{"dot host rejected", "20010101000000", "http://./x", ""},
+		{"dot-dot timestamp rejected", "..", "https://example.com/", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := timetraveller.SnapshotEntry{"key", tt.timestamp, tt.original, "text/html", "200", "A", "100"}
+			got, err := snapshotPath(dir, entry)
+			if tt.want == "" {
+				if err == nil {
+					t.Errorf("got %s, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := filepath.Join(dir, filepath.FromSlash(tt.want)); got != want {
+				t.Errorf("got %s, want %s", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,557 @@
+package main
+
+import (
+	"compress/gzip"
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aleister1102/timetraveller/pkg/timetraveller"
+)
+
+// engineFlags holds the flags shared by every command that queries the archive.
+type engineFlags struct {
+	numWorkers       int
+	batchHosts       int
+	inputFormat      string
+	inputStatus      string
+	keepDuplicates   bool
+	dedupScheme      bool
+	canonicalize     bool
+	defaultScheme    string
+	ignoreQuery      bool
+	ignoreFragment   bool
+	requestTimeoutMs int
+	userAgent        string
+	userAgentFile    string
+	headers          headerFlag
+	maxIdlePerHost   int
+	disableHTTP2     bool
+	disableKeepAlive bool
+	insecure         bool
+	caCert           string
+	tlsMinVersion    string
+	proxy            proxyFlag
+	proxyFile        string
+	proxyRotation    string
+	tor              bool
+	torSocks         string
+	torNewCircuit    int
+	delayMs          int
+	rps              float64
+	autoConcurrency  bool
+	breakerFailures  int
+	breakerCooldown  int
+	drainTimeoutMs   int
+	maxRuntime       time.Duration
+	hostConcurrency  int
+	retryOnBody      stringSliceFlag
+	retries          int
+	retryDelayMs     int
+	maxBackoffMs     int
+	from             string
+	to               string
+	filters          stringSliceFlag
+	anyStatus        bool
+	mimetypes        string
+	collapse         stringSliceFlag
+	pageSize         int
+	matchType        string
+	timeMap          string
+	provider         string
+	ccCrawls         int
+	cdxURL           string
+	playbackURL      string
+	archiveItColl    string
+	archiveItAuth    string
+	providerLimits   providerLimitFlag
+	cacheDir         string
+	cacheTTL         time.Duration
+	verbose          bool
+	debug            bool
+	logFile          string
+	logFormat        string
+	color            string
+	noColor          bool
+	gzipLevel        int
+	metricsAddr      string
+
+	torProxy       *torProxy        // Shared by every client of a -tor run
+	dispatched     func(url string) // If set, called as each URL is handed to a worker
+	metrics        *metrics         // Set by client with -metrics-listen
+	clientOnce     sync.Once
+	sharedClient   *timetraveller.Client
+	shutdownOnce   sync.Once
+	sharedShutdown *shutdown
+}
+
+// register defines every group of shared flags on fs, for the commands that
+// read input URLs and look them up in bulk.
+func (f *engineFlags) register(fs *flag.FlagSet) {
+	f.registerInput(fs)
+	f.registerWorkers(fs)
+	f.registerLookup(fs)
+	f.registerOutput(fs)
+}
+
+// registerInput defines the flags reading and preparing input URLs.
+func (f *engineFlags) registerInput(fs *flag.FlagSet) {
+	fs.StringVar(&f.inputFormat, "input-format", "urls", "Format of piped input: urls (one per line), httpx (httpx -json output), burp (Burp Suite XML export) or gnmap (nmap/masscan -oG output)")
+	fs.StringVar(&f.inputStatus, "input-status", "", "With -input-format httpx, only read URLs that answered with these comma-separated status codes")
+	fs.BoolVar(&f.keepDuplicates, "keep-duplicates", false, "Look up input URLs as given, without lowercasing hosts or skipping duplicates")
+	fs.BoolVar(&f.dedupScheme, "dedup-scheme", false, "Treat http://, https:// and scheme-less forms of an input URL as duplicates")
+	fs.BoolVar(&f.canonicalize, "canonicalize", false, "Rewrite input URLs to the Wayback index's canonical form (no www, session IDs or fragment; sorted query) so equivalent URLs share one lookup")
+	fs.StringVar(&f.defaultScheme, "default-scheme", "", "Scheme to give inputs without one: http, https, or both to look up each variant (default: none, matching any scheme)")
+	fs.BoolVar(&f.ignoreQuery, "ignore-query", false, "Drop the query string of input URLs, so crawler output full of unique parameters collapses to its paths")
+	fs.BoolVar(&f.ignoreFragment, "ignore-fragment", false, "Drop the #fragment of input URLs")
+}
+
+// registerWorkers defines the flags spreading lookups over workers, for the
+// commands that run many of them.
+func (f *engineFlags) registerWorkers(fs *flag.FlagSet) {
+	fs.IntVar(&f.numWorkers, "t", 10, "Number of concurrent goroutines (threads)")
+	fs.IntVar(&f.batchHosts, "batch-hosts", 0, "Look up the URLs of each host with at least this many inputs with one host-wide query, matching captures locally (0 = off; reads all input first)")
+	fs.IntVar(&f.delayMs, "d", 0, "Delay in milliseconds between each request sent by a worker (deprecated: the total rate grows with -t; use -rps)")
+	fs.BoolVar(&f.autoConcurrency, "auto", false, "Adapt concurrency to rate limiting: halve it on 429s and slowly grow back up to -t")
+	fs.IntVar(&f.drainTimeoutMs, "drain-timeout", 5000, "On SIGINT or SIGTERM, time in milliseconds to let in-flight requests finish before cancelling them")
+	fs.DurationVar(&f.maxRuntime, "max-runtime", 0, "Cancel the run after this long (e.g. 30m), keeping the results gathered so far (0 = no limit)")
+}
+
+// registerLookup defines the flags shaping archive requests and queries.
+func (f *engineFlags) registerLookup(fs *flag.FlagSet) {
+	fs.IntVar(&f.requestTimeoutMs, "to", 60000, "Timeout for each HTTP request in milliseconds")
+	fs.StringVar(&f.userAgent, "ua", defaultUserAgent, "User-Agent header sent with every request")
+	fs.StringVar(&f.userAgentFile, "ua-file", "", "File listing User-Agent strings one per line to rotate through per request (overrides -ua)")
+	fs.Var(&f.headers, "H", "Header sent with every request, as \"Name: value\" (repeatable), e.g. for cookies or gateway auth on a -cdx-url endpoint")
+	fs.IntVar(&f.maxIdlePerHost, "max-idle-conns-per-host", 0, "Idle connections kept open per host for reuse (0 = one per -t worker)")
+	fs.BoolVar(&f.disableHTTP2, "disable-http2", false, "Use HTTP/1.1 only, spreading requests over several connections instead of multiplexing one")
+	fs.BoolVar(&f.disableKeepAlive, "disable-keepalive", false, "Open a new connection for every request")
+	fs.BoolVar(&f.insecure, "insecure", false, "Skip TLS certificate verification, e.g. behind a TLS-intercepting proxy")
+	fs.StringVar(&f.caCert, "ca-cert", "", "PEM file of CA certificates to trust on top of the system roots")
+	fs.StringVar(&f.tlsMinVersion, "tls-min-version", "", "Minimum TLS version: 1.0, 1.1, 1.2 or 1.3 (default: Go's)")
+	fs.Var(&f.proxy, "proxy", "Proxy to send every request through, as http://, https:// or socks5:// URL with optional user:password (default: HTTP_PROXY/HTTPS_PROXY)")
+	fs.StringVar(&f.proxyFile, "proxy-file", "", "File listing proxy URLs one per line to spread requests across")
+	fs.StringVar(&f.proxyRotation, "proxy-rotation", "round-robin", "How requests pick a -proxy-file proxy: round-robin or random")
+	fs.BoolVar(&f.tor, "tor", false, "Route every request through the local Tor SOCKS proxy at -tor-socks")
+	fs.StringVar(&f.torSocks, "tor-socks", "127.0.0.1:9050", "Address of the Tor SOCKS proxy used by -tor")
+	fs.IntVar(&f.torNewCircuit, "tor-new-circuit", 0, "With -tor, switch to a new circuit after this many consecutive rate-limited responses (0 = never)")
+	fs.IntVar(&f.breakerFailures, "breaker", 20, "Pause all requests after this many consecutive network errors or 5xx responses (0 = never)")
+	fs.IntVar(&f.breakerCooldown, "breaker-cooldown", 60000, "How long in milliseconds the -breaker pause lasts")
+	fs.Float64Var(&f.rps, "rps", 0, "Maximum requests per second across all workers (0 = unlimited)")
+	fs.IntVar(&f.hostConcurrency, "host-concurrency", 0, "Maximum concurrent requests per host across all workers (0 = unlimited)")
+	defaults := timetraveller.DefaultOptions()
+	fs.IntVar(&f.retries, "retries", defaults.RetryAttempts, "Number of times to retry a failed or rate-limited request")
+	fs.IntVar(&f.retryDelayMs, "retry-delay", defaults.RetryDelayMs, "Base delay in milliseconds before a retry, doubled on each further attempt")
+	fs.IntVar(&f.maxBackoffMs, "max-backoff", defaults.MaxBackoffMs, "Maximum delay in milliseconds before a retry (0 = no cap)")
+	fs.Var(&f.retryOnBody, "retry-on-body", "Retry when a 200 response body contains this substring (repeatable)")
+	fs.Var(&f.filters, "filter", "CDX filter expression such as 'mimetype:text/html' or '!statuscode:404' (repeatable, replaces the default statuscode:200)")
+	fs.BoolVar(&f.anyStatus, "any-status", false, "Consider captures with any HTTP status (redirects, 4xx, ...), not only 200")
+	fs.StringVar(&f.mimetypes, "mime", "", "Only consider captures with these comma-separated mimetypes (e.g. text/html,application/json)")
+	fs.Var(&f.collapse, "collapse", "CDX collapse rule, e.g. 'timestamp:4' for one capture per year or 'digest' (repeatable; ignored by providers other than wayback and archive-it)")
+	fs.IntVar(&f.pageSize, "page-size", 0, "Rows to request per CDX page; further pages are followed via resume keys (0 = 10000, or the API default of a -cdx-url archive)")
+	fs.StringVar(&f.matchType, "match", "", "CDX match type: exact, prefix, host or domain")
+	fs.StringVar(&f.from, "from", "", "Only consider captures from this date on (YYYY, YYYYMM or YYYYMMDD)")
+	// "-to" is taken by the request timeout, so the upper bound is "-until".
+	fs.StringVar(&f.to, "until", "", "Only consider captures up to this date (YYYY, YYYYMM or YYYYMMDD)")
+	fs.StringVar(&f.timeMap, "timemap", "", "Read captures from this Memento TimeMap endpoint instead of the CDX API; the URL is appended (e.g. https://web.archive.org/web/timemap/link/)")
+	fs.StringVar(&f.provider, "provider", timetraveller.ProviderWayback, "Archive to query: wayback, archive.today, archive-it, arquivo, commoncrawl, or memento for the Memento aggregator covering many web archives; join several with commas to merge their captures")
+	fs.Var(&f.providerLimits, "provider-limit", "Request policy of one provider as 'name:rps=1,timeout=30000,retries=5,retry-delay=2000'; unset keys use the global flags (repeatable)")
+	fs.IntVar(&f.ccCrawls, "cc-crawls", timetraveller.DefaultCommonCrawlCrawls, "Number of most recent Common Crawl crawls to query with -provider commoncrawl (0 = every crawl, about 100 requests per lookup)")
+	fs.StringVar(&f.archiveItColl, "archive-it-collection", "", "Archive-It collection ID to query with -provider archive-it")
+	fs.StringVar(&f.archiveItAuth, "archive-it-auth", "", "Archive-It credentials as 'user:password' for private collections")
+	fs.StringVar(&f.cacheDir, "cache", "", "Directory caching the captures found per URL and query options, e.g. ~/.cache/timetraveller")
+	fs.DurationVar(&f.cacheTTL, "cache-ttl", 24*time.Hour, "How long -cache entries stay fresh (0 = forever)")
+	fs.StringVar(&f.metricsAddr, "metrics-listen", "", "Address to serve Prometheus metrics on at /metrics while running, e.g. :9090 (for watch and -every runs)")
+	fs.StringVar(&f.cdxURL, "cdx-url", "", "CDX API endpoint of a self-hosted archive (pywb, OpenWayback) to query instead of the Wayback Machine's")
+	fs.StringVar(&f.playbackURL, "playback-url", "", "Snapshot URL prefix of the -cdx-url archive, e.g. http://localhost:8080/my-coll/")
+}
+
+// registerOutput defines the flags of console output and logging.
+func (f *engineFlags) registerOutput(fs *flag.FlagSet) {
+	fs.BoolVar(&f.verbose, "v", false, "Log retries, backoff waits and rate-limit hits to stderr")
+	fs.BoolVar(&f.debug, "vv", false, "Like -v, and also log every request with its status and duration")
+	fs.StringVar(&f.logFile, "log-file", "", "File to append every message to, including the retries and rate-limit hits of -v (and requests with -vv), e.g. run.log")
+	fs.StringVar(&f.logFormat, "log-format", "json", "Format of -log-file records: json or text")
+	fs.StringVar(&f.color, "color", "auto", "When to color output: auto (on a terminal, unless NO_COLOR is set), always or never")
+	fs.BoolVar(&f.noColor, "no-color", false, "Disable color, like -color never")
+	fs.IntVar(&f.gzipLevel, "gzip-level", gzip.DefaultCompression, "Compression level for .gz output files (-2 to 9, -1 = default)")
+}
+
+// httpClient returns an HTTP client with the shared timeout, proxy and
+// User-Agent flags that also sends headers with every request.
+func (f *engineFlags) httpClient(headers http.Header) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	var err error
+	if transport.TLSClientConfig, err = tlsConfig(f.insecure, f.caCert, f.tlsMinVersion); err != nil {
+		fatalf("Error configuring TLS: %v", err)
+	}
+	// Go keeps only two idle connections per host by default, so most
+	// workers would open a new connection to the archive for every request.
+	transport.MaxIdleConnsPerHost = f.maxIdlePerHost
+	if transport.MaxIdleConnsPerHost <= 0 {
+		transport.MaxIdleConnsPerHost = f.numWorkers
+	}
+	transport.MaxIdleConns = max(transport.MaxIdleConns, transport.MaxIdleConnsPerHost)
+	transport.DisableKeepAlives = f.disableKeepAlive
+	if f.disableHTTP2 {
+		transport.ForceAttemptHTTP2 = false
+		// A non-nil empty map turns off Go's built-in HTTP/2.
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+	if f.proxy.url != nil {
+		transport.Proxy = http.ProxyURL(f.proxy.url)
+	}
+	if f.proxyFile != "" {
+		proxies, err := loadProxies(f.proxyFile)
+		if err != nil {
+			fatalf("Error reading proxy file: %v", err)
+		}
+		rotator := &proxyRotator{proxies: proxies, random: f.proxyRotation == "random"}
+		transport.Proxy = rotator.proxy
+	}
+	if f.tor {
+		if f.torProxy == nil {
+			f.torProxy = &torProxy{addr: f.torSocks}
+		}
+		transport.Proxy = f.torProxy.proxy
+	}
+	userAgents := []string{f.userAgent}
+	if f.userAgentFile != "" {
+		if userAgents, err = loadUserAgents(f.userAgentFile); err != nil {
+			fatalf("Error reading User-Agent file: %v", err)
+		}
+	}
+	return &http.Client{
+		Transport: &headerTransport{base: transport, userAgents: userAgents, headers: headers},
+		Timeout:   time.Duration(f.requestTimeoutMs) * time.Millisecond,
+	}
+}
+
+// client returns the lookup client configured by the shared flags. Every
+// call returns the same client, so all stages of a command share its limits.
+func (f *engineFlags) client() *timetraveller.Client {
+	f.clientOnce.Do(func() {
+		f.sharedClient = timetraveller.NewClient(f.httpClient(http.Header(f.headers)))
+		f.sharedClient.Limiter = timetraveller.NewHostLimiter(f.hostConcurrency)
+		f.sharedClient.RateLimiter = timetraveller.NewRateLimiter(f.rps)
+		f.sharedClient.ProviderSettings = f.providerSettings()
+		f.sharedClient.Cache = timetraveller.NewCache(expandHome(f.cacheDir), f.cacheTTL)
+		f.sharedClient.Logger = f.logger()
+		f.sharedClient.Breaker = timetraveller.NewCircuitBreaker(f.breakerFailures, time.Duration(f.breakerCooldown)*time.Millisecond)
+		if f.sharedClient.Breaker != nil {
+			f.sharedClient.Breaker.OnOpen = func(failures int, cooldown time.Duration) {
+				slog.Error("Consecutive failures; pausing all requests", "failures", failures, "cooldown", cooldown)
+			}
+		}
+		if f.torProxy != nil && f.torNewCircuit > 0 {
+			f.sharedClient.OnRateLimited = func(consecutive int) {
+				if consecutive%f.torNewCircuit != 0 {
+					return
+				}
+				f.torProxy.newCircuit()
+				f.sharedClient.HTTPClient.CloseIdleConnections()
+				slog.Info("Consecutive rate-limited responses; switching to a new Tor circuit", "consecutive", consecutive)
+			}
+		}
+		if f.autoConcurrency {
+			f.sharedClient.Adaptive = timetraveller.NewAdaptiveLimiter(f.numWorkers)
+			f.sharedClient.Adaptive.OnChange = func(limit int) {
+				slog.Info("Concurrency adjusted", "limit", limit)
+			}
+		}
+		if f.metricsAddr != "" {
+			f.metrics = newMetrics(f.sharedClient)
+			if err := f.metrics.serve(f.metricsAddr); err != nil {
+				fatalf("Error serving metrics: %v", err)
+			}
+		}
+	})
+	return f.sharedClient
+}
+
+// logger returns the client's logger, showing its messages on the console
+// with -v or -vv, or nil if they go nowhere.
+func (f *engineFlags) logger() *slog.Logger {
+	switch {
+	case f.debug:
+		return clientLogger(slog.LevelDebug)
+	case f.verbose:
+		return clientLogger(slog.LevelInfo)
+	}
+	return clientLogger(nil)
+}
+
+// providerSettings returns the client settings of the providers named by
+// -provider-limit, taking unset values from the shared flags.
+func (f *engineFlags) providerSettings() map[string]timetraveller.ProviderSettings {
+	if len(f.providerLimits) == 0 {
+		return nil
+	}
+	settings := make(map[string]timetraveller.ProviderSettings, len(f.providerLimits))
+	for name, limit := range f.providerLimits {
+		s := timetraveller.ProviderSettings{
+			RateLimiter:   timetraveller.NewRateLimiter(limit.rps),
+			Timeout:       time.Duration(limit.timeoutMs) * time.Millisecond,
+			RetryAttempts: f.retries,
+			RetryDelayMs:  f.retryDelayMs,
+		}
+		if limit.retries >= 0 {
+			s.RetryAttempts = limit.retries
+		}
+		if limit.retryDelayMs >= 0 {
+			s.RetryDelayMs = limit.retryDelayMs
+		}
+		settings[name] = s
+	}
+	return settings
+}
+
+// inputURLs streams the input URLs given as arguments or piped in the
+// -input-format.
+func (f *engineFlags) inputURLs(args []string) (<-chan string, error) {
+	var statuses []int
+	for _, code := range splitList(f.inputStatus) {
+		status, err := strconv.Atoi(code)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -input-status %q: expected status codes", f.inputStatus)
+		}
+		statuses = append(statuses, status)
+	}
+	if len(statuses) > 0 && f.inputFormat != "httpx" {
+		return nil, fmt.Errorf("-input-status needs -input-format httpx")
+	}
+	switch f.defaultScheme {
+	case "", "http", "https", "both":
+	default:
+		return nil, fmt.Errorf("invalid -default-scheme %q: expected http, https or both", f.defaultScheme)
+	}
+
+	var parse inputParser
+	switch f.inputFormat {
+	case "urls":
+		parse = parseURLLines
+	case "httpx":
+		parse = httpxParser(statuses)
+	case "burp":
+		parse = parseBurpXML
+	case "gnmap":
+		parse = parseGnmap
+	default:
+		return nil, fmt.Errorf("invalid -input-format %q: expected one of %s", f.inputFormat, strings.Join(inputFormats, ", "))
+	}
+	return streamInputURLs(args, parse), nil
+}
+
+// prepareInput validates the input URLs, converts internationalized hostnames
+// to punycode, adds the -default-scheme, strips the parts named by
+// -ignore-query and -ignore-fragment and canonicalizes them with
+// -canonicalize, then normalizes and deduplicates them unless
+// -keep-duplicates is set. The returned stats count the skipped URLs.
+func (f *engineFlags) prepareInput(urls <-chan string) (<-chan string, *inputStats) {
+	stats := new(inputStats)
+	urls = validateURLs(urls, &stats.invalid)
+	urls = mapURLs(urls, timetraveller.PunycodeURL)
+	if f.defaultScheme != "" {
+		urls = addScheme(urls, f.defaultScheme)
+	}
+	if f.ignoreQuery || f.ignoreFragment {
+		urls = mapURLs(urls, func(u string) string {
+			return stripURLParts(u, f.ignoreQuery, f.ignoreFragment)
+		})
+	}
+	if f.canonicalize {
+		urls = mapURLs(urls, timetraveller.Canonicalize)
+	}
+	if !f.keepDuplicates {
+		urls = dedupURLs(urls, f.dedupScheme, &stats.duplicates)
+	}
+	return urls, stats
+}
+
+// readInput reads every input URL with inputURLs and prepares them with
+// prepareInput, for commands that need the whole list up front.
+func (f *engineFlags) readInput(args []string) ([]string, *inputStats, error) {
+	input, err := f.inputURLs(args)
+	if err != nil {
+		return nil, nil, err
+	}
+	prepared, stats := f.prepareInput(input)
+	var urls []string
+	for u := range prepared {
+		urls = append(urls, u)
+	}
+	return urls, stats, nil
+}
+
+// lookupOptions returns the lookup options derived from the shared flags.
+func (f *engineFlags) lookupOptions() (timetraveller.Options, error) {
+	opts := timetraveller.DefaultOptions()
+	if f.retries < 0 || f.retryDelayMs < 0 || f.maxBackoffMs < 0 {
+		return opts, fmt.Errorf("-retries, -retry-delay and -max-backoff must not be negative")
+	}
+	proxies := 0
+	for _, set := range []bool{f.proxy.url != nil, f.proxyFile != "", f.tor} {
+		if set {
+			proxies++
+		}
+	}
+	if proxies > 1 {
+		return opts, fmt.Errorf("only one of -proxy, -proxy-file and -tor can be used")
+	}
+	if f.proxyRotation != "round-robin" && f.proxyRotation != "random" {
+		return opts, fmt.Errorf("invalid -proxy-rotation %q: expected round-robin or random", f.proxyRotation)
+	}
+	opts.RetryAttempts = f.retries
+	opts.RetryDelayMs = f.retryDelayMs
+	opts.MaxBackoffMs = f.maxBackoffMs
+	opts.RetryOnBody = f.retryOnBody
+	for name, bound := range map[string]string{"-from": f.from, "-until": f.to} {
+		if bound == "" {
+			continue
+		}
+		if !isDigits(bound) {
+			return opts, fmt.Errorf("invalid %s %q: expected YYYY, YYYYMM or YYYYMMDD", name, bound)
+		}
+		if _, err := timetraveller.ParseTimestamp(bound); err != nil {
+			return opts, fmt.Errorf("invalid %s: %w", name, err)
+		}
+	}
+	opts.From, opts.To = f.from, f.to
+	opts.Filters = f.filters
+	opts.AnyStatus = f.anyStatus
+	opts.Mimetypes = splitList(f.mimetypes)
+	opts.Collapse = f.collapse
+	opts.PageSize = f.pageSize
+	opts.TimeMap = f.timeMap
+	providers := strings.Split(f.provider, ",")
+	for _, provider := range providers {
+		if !slices.Contains(timetraveller.Providers(), provider) {
+			return opts, fmt.Errorf("invalid -provider %q: expected one of %s", provider, strings.Join(timetraveller.Providers(), ", "))
+		}
+	}
+	opts.Provider = f.provider
+	opts.CommonCrawlCrawls = f.ccCrawls
+	if slices.Contains(providers, timetraveller.ProviderArchiveIt) && !isDigits(f.archiveItColl) {
+		return opts, fmt.Errorf("-provider archive-it needs a numeric -archive-it-collection")
+	}
+	if f.archiveItAuth != "" && !strings.Contains(f.archiveItAuth, ":") {
+		return opts, fmt.Errorf("invalid -archive-it-auth: expected user:password")
+	}
+	opts.ArchiveItCollection = f.archiveItColl
+	opts.ArchiveItAuth = f.archiveItAuth
+	opts.CDXURL = f.cdxURL
+	opts.PlaybackURL = f.playbackURL
+	switch f.matchType {
+	case "", timetraveller.MatchExact, timetraveller.MatchPrefix, timetraveller.MatchHost, timetraveller.MatchDomain:
+		opts.MatchType = f.matchType
+	default:
+		return opts, fmt.Errorf("invalid -match %q: expected exact, prefix, host or domain", f.matchType)
+	}
+	return opts, nil
+}
+
+// stringSliceFlag collects the values of a repeatable string flag.
+type stringSliceFlag []string
+
+func (s *stringSliceFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringSliceFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// providerLimit is the request policy given to one provider by -provider-limit;
+// negative retry values are unset.
+type providerLimit struct {
+	rps          float64
+	timeoutMs    int
+	retries      int
+	retryDelayMs int
+}
+
+// providerLimitFlag collects the values of the repeatable -provider-limit
+// flag by provider name.
+type providerLimitFlag map[string]providerLimit
+
+func (p *providerLimitFlag) String() string {
+	names := make([]string, 0, len(*p))
+	for name := range *p {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return strings.Join(names, ",")
+}
+
+func (p *providerLimitFlag) Set(value string) error {
+	name, settings, _ := strings.Cut(value, ":")
+	if !slices.Contains(timetraveller.Providers(), name) {
+		return fmt.Errorf("unknown provider %q: expected one of %s", name, strings.Join(timetraveller.Providers(), ", "))
+	}
+	limit := providerLimit{retries: -1, retryDelayMs: -1}
+	for _, setting := range splitList(settings) {
+		key, val, _ := strings.Cut(setting, "=")
+		var err error
+		switch key {
+		case "rps":
+			limit.rps, err = strconv.ParseFloat(val, 64)
+		case "timeout":
+			limit.timeoutMs, err = strconv.Atoi(val)
+		case "retries":
+			limit.retries, err = strconv.Atoi(val)
+		case "retry-delay":
+			limit.retryDelayMs, err = strconv.Atoi(val)
+		default:
+			return fmt.Errorf("unknown setting %q: expected rps, timeout, retries or retry-delay", key)
+		}
+		if err != nil || strings.HasPrefix(val, "-") {
+			return fmt.Errorf("invalid %s %q", key, val)
+		}
+	}
+	if *p == nil {
+		*p = make(providerLimitFlag)
+	}
+	(*p)[name] = limit
+	return nil
+}
+
+// proxyFlag holds the proxy URL given with -proxy.
+type proxyFlag struct {
+	url *url.URL
+}
+
+func (p *proxyFlag) String() string {
+	if p.url == nil {
+		return ""
+	}
+	return p.url.Redacted()
+}
+
+func (p *proxyFlag) Set(value string) error {
+	u, err := parseProxyURL(value)
+	if err != nil {
+		return err
+	}
+	p.url = u
+	return nil
+}
+
+// flagPassed reports whether the named flag was set on the command line.
+func flagPassed(fs *flag.FlagSet, name string) bool {
+	passed := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			passed = true
+		}
+	})
+	return passed
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"flag"
+	"slices"
+	"testing"
+)
+
+func TestEngineFlagGroups(t *testing.T) {
+	tests := []struct {
+		name      string
+		register  func(f *engineFlags, fs *flag.FlagSet)
+		defined   []string
+		undefined []string
+	}{
+		{"every group", (*engineFlags).register,
+			[]string{"input-format", "canonicalize", "t", "batch-hosts", "to", "provider", "cdx-url", "v", "color"}, nil},
+		{"input", (*engineFlags).registerInput,
+			[]string{"input-format", "input-status", "keep-duplicates", "default-scheme", "ignore-query"}, []string{"t", "provider", "v"}},
+		{"workers", (*engineFlags).registerWorkers,
+			[]string{"t", "d", "batch-hosts", "auto", "drain-timeout", "max-runtime"}, []string{"input-format", "to", "color"}},
+		{"lookup", (*engineFlags).registerLookup,
+			[]string{"to", "retries", "proxy", "provider", "from", "until", "cdx-url", "cache"}, []string{"input-format", "t", "log-file"}},
+		{"output", (*engineFlags).registerOutput,
+			[]string{"v", "vv", "log-file", "log-format", "color", "no-color", "gzip-level"}, []string{"input-format", "t", "to"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var f engineFlags
+			fs := flag.NewFlagSet(tt.name, flag.ContinueOnError)
+			tt.register(&f, fs)
+			for _, name := range tt.defined {
+				if fs.Lookup(name) == nil {
+					t.Errorf("-%s not defined", name)
+				}
+			}
+			for _, name := range tt.undefined {
+				if fs.Lookup(name) != nil {
+					t.Errorf("-%s defined by another group", name)
+				}
+			}
+		})
+	}
+}
+
+func TestLookupOptionsWithoutInputOrWorkers(t *testing.T) {
+	// diff registers only the lookup and output groups: their defaults alone
+	// must give valid options.
+	var f engineFlags
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	f.registerLookup(fs)
+	f.registerOutput(fs)
+	if err := fs.Parse([]string{"-from", "2010"}); err != nil {
+		t.Fatal(err)
+	}
+	opts, err := f.lookupOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.From != "2010" || opts.RetryAttempts != 3 {
+		t.Errorf("got From %q and %d retries, want 2010 and 3", opts.From, opts.RetryAttempts)
+	}
+}
+
+func TestReadInput(t *testing.T) {
+	tests := []struct {
+		name  string
+		flags []string
+		args  []string
+		want  []string
+	}{
+		{"duplicates dropped", nil, []string{"https://Example.com/a", "https://example.com/a"}, []string{"https://example.com/a"}},
+		{"duplicates kept", []string{"-keep-duplicates"}, []string{"https://example.com/a", "https://example.com/a"}, []string{"https://example.com/a", "https://example.com/a"}},
+		{"default scheme added", []string{"-default-scheme", "both"}, []string{"example.com"}, []string{"http://example.com", "https://example.com"}},
+		{"query and fragment stripped", []string{"-ignore-query", "-ignore-fragment"}, []string{"https://example.com/a?q=1#top"}, []string{"https://example.com/a"}},
+		{"canonicalized", []string{"-canonicalize"}, []string{"https://www.example.com/A?b=2&a=1"}, []string{"example.com/a?a=1&b=2"}},
+		{"hostname converted to punycode", nil, []string{"https://bücher.example/"}, []string{"https://xn--bcher-kva.example/"}},
+		{"malformed URL skipped", nil, []string{"https://exa mple.com/", "https://example.com/"}, []string{"https://example.com/"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var f engineFlags
+			fs := flag.NewFlagSet("urls", flag.ContinueOnError)
+			f.register(fs)
+			if err := fs.Parse(tt.flags); err != nil {
+				t.Fatal(err)
+			}
+			got, _, err := f.readInput(tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadInputRejectsInvalidFlags(t *testing.T) {
+	for _, flags := range [][]string{{"-default-scheme", "ftp"}, {"-input-format", "xml"}, {"-input-status", "200"}} {
+		var f engineFlags
+		fs := flag.NewFlagSet("urls", flag.ContinueOnError)
+		f.register(fs)
+		if err := fs.Parse(flags); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := f.readInput([]string{"example.com"}); err == nil {
+			t.Errorf("%q accepted", flags)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/aleister1102/timetraveller/pkg/lookuppb"
+	"github.com/aleister1102/timetraveller/pkg/timetraveller"
+	"google.golang.org/grpc"
+)
+
+// lookupService is the gRPC Lookup service of "serve": every stream runs its
+// lookups on the same engine, limits and metrics as the HTTP API.
+type lookupService struct {
+	lookuppb.UnimplementedLookupServer
+	f    *engineFlags
+	opts timetraveller.Options
+}
+
+// Lookup looks up the URLs of the stream as they arrive and sends each result
+// once ready. Malformed URLs get an error result without a lookup.
+func (s *lookupService) Lookup(stream grpc.BidiStreamingServer[lookuppb.LookupRequest, lookuppb.LookupResult]) error {
+	var mu sync.Mutex
+	all := make(map[string]int) // Pending URLs whose result lists every capture
+	invalid := make(chan timetraveller.ProcessResult, 1)
+	urls := make(chan string)
+	go func() {
+		defer close(urls)
+		defer close(invalid)
+		for {
+			req, err := stream.Recv()
+			if err != nil {
+				// io.EOF once the client is done sending, or a broken stream.
+				return
+			}
+			if err := validateInputURL(req.GetUrl()); err != nil {
+				invalid <- timetraveller.ProcessResult{URL: req.GetUrl(), Status: timetraveller.StatusError, Error: err}
+				continue
+			}
+			u := timetraveller.PunycodeURL(req.GetUrl())
+			if req.GetAll() {
+				mu.Lock()
+				all[u]++
+				mu.Unlock()
+			}
+			select {
+			case urls <- u:
+			case <-stream.Context().Done():
+				return
+			}
+		}
+	}()
+
+	results := startLookups(s.f, urls, s.opts)
+	var sendErr error
+	for results != nil || invalid != nil {
+		var result timetraveller.ProcessResult
+		var ok bool
+		select {
+		case result, ok = <-results:
+			if !ok {
+				results = nil
+				continue
+			}
+		case result, ok = <-invalid:
+			if !ok {
+				invalid = nil
+				continue
+			}
+		}
+		mu.Lock()
+		listAll := all[result.URL] > 0
+		if listAll {
+			all[result.URL]--
+		}
+		mu.Unlock()
+		// After a failed send, keep draining so the workers can finish.
+		if sendErr == nil {
+			sendErr = stream.Send(newLookupResult(result, listAll))
+		}
+	}
+	return sendErr
+}
+
+// newLookupResult converts r to its gRPC form, listing every capture if all
+// is set.
+func newLookupResult(r timetraveller.ProcessResult, all bool) *lookuppb.LookupResult {
+	result := &lookuppb.LookupResult{
+		Url:           r.URL,
+		Status:        r.Status,
+		SnapshotCount: int32(r.SnapshotCount),
+		SnapshotUrl:   r.OldestURL,
+		DetailsUrl:    r.DetailsURL,
+	}
+	if r.Error != nil {
+		result.Error = r.Error.Error()
+	}
+	if all {
+		for _, entry := range r.Snapshots {
+			result.Snapshots = append(result.Snapshots, &lookuppb.Snapshot{
+				Timestamp: entry.Field(timetraveller.FieldTimestamp),
+				Url:       entry.ArchiveURL(),
+				Digest:    entry.Field(timetraveller.FieldDigest),
+				Archive:   entry.Archive(),
+			})
+		}
+	}
+	return result
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"log/slog"
+
+	"github.com/aleister1102/timetraveller/pkg/timetraveller"
+)
+
+// harvestOriginals queries the archive for every URL captured under each
+// domain (one row per unique URL key) and calls emit once with each original
+// URL of a domain. Providers that cannot collapse (Common Crawl, arquivo.pt,
+// pywb) list every capture, so originals are deduplicated here.
+func harvestOriginals(f *engineFlags, domains []string, opts timetraveller.Options, emit func(domain, original string)) {
+	// Any capture proves a URL existed, whatever its status.
+	opts.AnyStatus = true
+	opts.Collapse = append(opts.Collapse, "urlkey")
+	opts.Fields = []string{"original"}
+
+	processed := 0
+	for result := range startLookups(f, sendURLs(domains), opts) {
+		processed++
+		if result.Error != nil {
+			slog.Error("Lookup failed", "url", result.URL, "error", result.Error)
+			continue
+		}
+		seen := make(map[string]bool)
+		for _, row := range result.Snapshots {
+			if original := row.Field(0); original != "" && !seen[original] {
+				seen[original] = true
+				emit(result.URL, original)
+			}
+		}
+	}
+	f.reportInterrupted("%d of %d domains processed", processed, len(domains))
+}
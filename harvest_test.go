@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestHarvestOriginalsDeduplicates(t *testing.T) {
+	originals := []string{"https://a.example/", "https://a.example/x", "https://a.example/", "https://a.example/x", "https://a.example/y"}
+	tests := []struct {
+		name  string
+		serve func(w http.ResponseWriter)
+	}{
+		{"CDX rows", func(w http.ResponseWriter) {
+			fmt.Fprint(w, `[["original"]`)
+			for _, original := range originals {
+				fmt.Fprintf(w, `,[%q]`, original)
+			}
+			fmt.Fprint(w, `]`)
+		}},
+		{"pywb records ignoring the collapse rule", func(w http.ResponseWriter) {
+			for i, original := range originals {
+				fmt.Fprintf(w, "{\"url\": %q, \"timestamp\": \"2001010100000%d\"}\n", original, i)
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				tt.serve(w)
+			}))
+			defer server.Close()
+
+			var f engineFlags
+			fs := flag.NewFlagSet("urls", flag.ContinueOnError)
+			f.register(fs)
+			if err := fs.Parse([]string{"-retries", "0", "-cdx-url", server.URL}); err != nil {
+				t.Fatal(err)
+			}
+			opts, err := f.lookupOptions()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			harvestOriginals(&f, []string{"a.example"}, opts, func(_, original string) {
+				got = append(got, original)
+			})
+			if want := []string{"https://a.example/", "https://a.example/x", "https://a.example/y"}; !slices.Equal(got, want) {
+				t.Errorf("emitted %q, want %q", got, want)
+			}
+		})
+	}
+}
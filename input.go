@@ -0,0 +1,216 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"github.com/aleister1102/timetraveller/pkg/timetraveller"
+)
+
+// stdinPiped reports whether stdin is a pipe or file rather than a terminal.
+func stdinPiped() bool {
+	stat, _ := os.Stdin.Stat()
+	return stat != nil && stat.Mode()&os.ModeCharDevice == 0
+}
+
+// streamInputURLs sends the URLs given as arguments or, if there are none and
+// stdin is piped, those read from stdin with parse. Each URL is sent as soon
+// as it is read, so lookups start while an upstream tool is still producing
+// input. A read error is reported on stderr and ends
+// the stream.
+func streamInputURLs(args []string, parse inputParser) <-chan string {
+	if len(args) > 0 || !stdinPiped() {
+		return sendURLs(args)
+	}
+	urls := make(chan string)
+	go func() {
+		defer close(urls)
+		err := parse(os.Stdin, func(u string) { urls <- u })
+		if err != nil {
+			slog.Error("Error reading from stdin", "error", err)
+		}
+	}()
+	return urls
+}
+
+// sendURLs sends urls on the returned channel, which is closed after the last.
+func sendURLs(urls []string) <-chan string {
+	out := make(chan string)
+	go func() {
+		defer close(out)
+		for _, u := range urls {
+			out <- u
+		}
+	}()
+	return out
+}
+
+// concatURLs passes on the URLs of in followed by more.
+func concatURLs(in <-chan string, more []string) <-chan string {
+	out := make(chan string)
+	go func() {
+		defer close(out)
+		for u := range in {
+			out <- u
+		}
+		for _, u := range more {
+			out <- u
+		}
+	}()
+	return out
+}
+
+// mapURLs passes on the URLs of in as rewritten by fn.
+func mapURLs(in <-chan string, fn func(string) string) <-chan string {
+	out := make(chan string)
+	go func() {
+		defer close(out)
+		for u := range in {
+			out <- fn(u)
+		}
+	}()
+	return out
+}
+
+// stripURLParts removes the query string of u if query is set, and its
+// fragment if fragment is set.
+func stripURLParts(u string, query, fragment bool) string {
+	rest, frag, hasFrag := strings.Cut(u, "#")
+	if query {
+		rest, _, _ = strings.Cut(rest, "?")
+	}
+	if hasFrag && !fragment {
+		return rest + "#" + frag
+	}
+	return rest
+}
+
+// filterURLs passes on the URLs of in for which keep returns true. keep is
+// called from a single goroutine.
+func filterURLs(in <-chan string, keep func(string) bool) <-chan string {
+	out := make(chan string)
+	go func() {
+		defer close(out)
+		for u := range in {
+			if keep(u) {
+				out <- u
+			}
+		}
+	}()
+	return out
+}
+
+// inputStats counts the input URLs skipped while preparing the input.
+type inputStats struct {
+	invalid    atomic.Int64
+	duplicates atomic.Int64
+}
+
+// report prints the skipped counts on stderr.
+func (s *inputStats) report() {
+	if n := s.invalid.Load(); n > 0 {
+		slog.Info("Skipped malformed input URLs", "count", n)
+	}
+	if n := s.duplicates.Load(); n > 0 {
+		slog.Info("Skipped duplicate input URLs", "count", n)
+	}
+}
+
+// validateInputURL reports why u cannot be looked up, if it cannot.
+func validateInputURL(u string) error {
+	target := u
+	if !strings.Contains(target, "://") {
+		target = "http://" + target
+	}
+	parsed, err := url.Parse(target)
+	if err != nil {
+		return err
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return fmt.Errorf("unsupported scheme %q", parsed.Scheme)
+	}
+	if parsed.Hostname() == "" {
+		return fmt.Errorf("missing host")
+	}
+	if strings.ContainsAny(parsed.Hostname(), " \t\"<>\\^`{|}") {
+		return fmt.Errorf("invalid host %q", parsed.Hostname())
+	}
+	return nil
+}
+
+// validateURLs passes on the valid URLs of in, reporting the others on
+// stderr and counting them in invalid.
+func validateURLs(in <-chan string, invalid *atomic.Int64) <-chan string {
+	return filterURLs(in, func(u string) bool {
+		if err := validateInputURL(u); err != nil {
+			invalid.Add(1)
+			slog.Warn("Skipping malformed input", "url", u, "error", err)
+			return false
+		}
+		return true
+	})
+}
+
+// addScheme passes on the URLs of in, prefixing those without a scheme with
+// scheme, or with both http:// and https:// if scheme is "both".
+func addScheme(in <-chan string, scheme string) <-chan string {
+	out := make(chan string)
+	go func() {
+		defer close(out)
+		for u := range in {
+			switch {
+			case strings.Contains(u, "://"):
+				out <- u
+			case scheme == "both":
+				out <- "http://" + u
+				out <- "https://" + u
+			default:
+				out <- scheme + "://" + u
+			}
+		}
+	}()
+	return out
+}
+
+// dedupURLs passes on the URLs of in with their scheme and host lowercased,
+// skipping those equivalent to one already seen and counting them in skipped.
+func dedupURLs(in <-chan string, ignoreScheme bool, skipped *atomic.Int64) <-chan string {
+	seen := make(map[[sha256.Size]byte]struct{})
+	out := make(chan string)
+	go func() {
+		defer close(out)
+		for u := range in {
+			u = timetraveller.NormalizeURL(u)
+			key := sha256.Sum256([]byte(dedupKey(u, ignoreScheme)))
+			if _, ok := seen[key]; ok {
+				skipped.Add(1)
+				continue
+			}
+			seen[key] = struct{}{}
+			out <- u
+		}
+	}()
+	return out
+}
+
+// dedupKey returns the form of a normalized URL under which duplicates
+// collide: without a trailing slash on the path and, if ignoreScheme is set,
+// without an http or https scheme.
+func dedupKey(u string, ignoreScheme bool) string {
+	if ignoreScheme {
+		if scheme, rest, ok := strings.Cut(u, "://"); ok && (scheme == "http" || scheme == "https") {
+			u = rest
+		}
+	}
+	path, query, hasQuery := strings.Cut(u, "?")
+	path = strings.TrimSuffix(path, "/")
+	if hasQuery {
+		return path + "?" + query
+	}
+	return path
+}
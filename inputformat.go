@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// maxInputLine bounds a line of piped input; httpx lines can carry whole
+// response bodies.
+const maxInputLine = 16 << 20
+
+// inputParser reads the input URLs from r, calling emit for each.
+type inputParser func(r io.Reader, emit func(string)) error
+
+// inputFormats lists the -input-format values.
+var inputFormats = []string{"urls", "httpx", "burp", "gnmap"}
+
+// scanLines calls fn with each non-empty, trimmed line of r.
+func scanLines(r io.Reader, fn func(line string)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxInputLine)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			fn(line)
+		}
+	}
+	return scanner.Err()
+}
+
+// parseURLLines reads one URL per line.
+func parseURLLines(r io.Reader, emit func(string)) error {
+	return scanLines(r, emit)
+}
+
+// httpxParser reads httpx's JSON lines output (-json), keeping the url of the
+// responses whose status code is in statuses (any, if empty).
+func httpxParser(statuses []int) inputParser {
+	return func(r io.Reader, emit func(string)) error {
+		return scanLines(r, func(line string) {
+			var record struct {
+				URL        string `json:"url"`
+				StatusCode int    `json:"status_code"`
+			}
+			if err := json.Unmarshal([]byte(line), &record); err != nil || record.URL == "" {
+				slog.Warn("Skipping input line that is not an httpx JSON record", "line", fmt.Sprintf("%.80s", line))
+				return
+			}
+			if len(statuses) > 0 && !slices.Contains(statuses, record.StatusCode) {
+				return
+			}
+			emit(record.URL)
+		})
+	}
+}
+
+// parseBurpXML reads a Burp Suite XML export (proxy history or site map),
+// emitting the URL of each request item once.
+func parseBurpXML(r io.Reader, emit func(string)) error {
+	decoder := xml.NewDecoder(r)
+	seen := make(map[string]bool)
+	var path []string // Names of the open elements
+	var text strings.Builder
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			path = append(path, t.Name.Local)
+			text.Reset()
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			// <items><item><url>...</url></item></items>
+			if len(path) >= 2 && t.Name.Local == "url" && path[len(path)-2] == "item" {
+				if u := strings.TrimSpace(text.String()); u != "" && !seen[u] {
+					seen[u] = true
+					emit(u)
+				}
+			}
+			if len(path) > 0 {
+				path = path[:len(path)-1]
+			}
+			text.Reset()
+		}
+	}
+}
+
+// Ports assumed to serve HTTP(S) when the scanner did not identify the service.
+var (
+	httpPorts  = []int{80, 81, 591, 3000, 5000, 8000, 8008, 8080, 8081, 8888}
+	httpsPorts = []int{443, 4443, 8443, 9443}
+)
+
+// parseGnmap reads nmap grepable output (-oG), which masscan also writes,
+// emitting an http:// or https:// URL for each open web port. Hosts are named
+// by their hostname when the scan resolved one.
+func parseGnmap(r io.Reader, emit func(string)) error {
+	return scanLines(r, func(line string) {
+		var host, ports string
+		for _, field := range strings.Split(line, "\t") {
+			if rest, ok := strings.CutPrefix(field, "Host: "); ok {
+				// "10.0.0.1 (example.com)"
+				ip, name, _ := strings.Cut(rest, " ")
+				host = strings.Trim(name, "()")
+				if host == "" {
+					host = ip
+				}
+			} else if rest, ok := strings.CutPrefix(field, "Ports: "); ok {
+				ports = rest
+			}
+		}
+		if host == "" || ports == "" {
+			return
+		}
+		for _, entry := range strings.Split(ports, ",") {
+			// port/state/protocol/owner/service/rpc info/version
+			parts := strings.Split(strings.TrimSpace(entry), "/")
+			if len(parts) < 3 || parts[1] != "open" || parts[2] != "tcp" {
+				continue
+			}
+			port, err := strconv.Atoi(parts[0])
+			if err != nil {
+				continue
+			}
+			service := ""
+			if len(parts) > 4 {
+				service = parts[4]
+			}
+			if scheme := webScheme(port, service); scheme != "" {
+				emit(webURL(scheme, host, port))
+			}
+		}
+	})
+}
+
+// webScheme returns the scheme a port serves, from the detected service or
+// else the port number, or "" if it is not a web port.
+func webScheme(port int, service string) string {
+	switch {
+	case strings.Contains(service, "https") || (strings.Contains(service, "ssl") && strings.Contains(service, "http")):
+		return "https"
+	case strings.Contains(service, "http"):
+		return "http"
+	case service != "":
+		return ""
+	case slices.Contains(httpsPorts, port):
+		return "https"
+	case slices.Contains(httpPorts, port):
+		return "http"
+	}
+	return ""
+}
+
+// webURL returns the root URL of host on port, omitting the scheme's default port.
+func webURL(scheme, host string, port int) string {
+	if (scheme == "http" && port == 80) || (scheme == "https" && port == 443) {
+		return scheme + "://" + host + "/"
+	}
+	return scheme + "://" + host + ":" + strconv.Itoa(port) + "/"
+}
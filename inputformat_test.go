@@ -0,0 +1,129 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseBurpXML(t *testing.T) {
+	tests := []struct {
+		name    string
+		xml     string
+		want    []string
+		wantErr bool
+	}{
+		{"proxy history", `<?xml version="1.0"?>
+<!DOCTYPE items [<!ELEMENT items (item*)>]>
+<items burpVersion="2023.10" exportTime="Mon Jan 01 00:00:00 UTC 2024">
+  <item>
+    <time>Mon Jan 01 00:00:00 UTC 2024</time>
+    <url><![CDATA[https://example.com/login?next=/]]></url>
+    <host ip="93.184.216.34">example.com</host>
+    <request base64="true"><![CDATA[R0VUIC8gSFRUUC8xLjE=]]></request>
+  </item>
+  <item>
+    <url>
+      https://example.com/api/v1/users
+    </url>
+  </item>
+</items>`, []string{"https://example.com/login?next=/", "https://example.com/api/v1/users"}, false},
+		{"repeated requests emitted once", `<items>
+  <item><url>https://example.com/</url></item>
+  <item><url>https://example.com/a</url></item>
+  <item><url>https://example.com/</url></item>
+</items>`, []string{"https://example.com/", "https://example.com/a"}, false},
+		{"url elements outside items ignored", `<items>
+  <url>https://outside.example/</url>
+  <item><comment><url>https://nested.example/</url></comment><url>https://example.com/</url></item>
+</items>`, []string{"https://example.com/"}, false},
+		{"empty urls skipped", `<items><item><url> </url></item><item><url/></item></items>`, nil, false},
+		{"no items", `<items></items>`, nil, false},
+		{"malformed XML", `<items><item><url>https://example.com/</url>`, []string{"https://example.com/"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			err := parseBurpXML(strings.NewReader(tt.xml), func(u string) { got = append(got, u) })
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error %v, want error %v", err, tt.wantErr)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHTTPXParser(t *testing.T) {
+	records := strings.Join([]string{
+		`{"timestamp":"2024-01-01T00:00:00Z","url":"https://example.com","input":"example.com","status_code":200,"title":"Example"}`,
+		`{"url":"http://example.com:8080/admin","input":"example.com:8080","status_code":403}`,
+		`{"url":"https://example.com/old","input":"https://example.com/old","status_code":301,"final_url":"https://example.com/new"}`,
+		`{"input":"down.example","failed":true,"error":"no address found for host"}`,
+		`{"url":"https://timeout.example","input":"timeout.example","failed":true}`,
+		`https://plain.example/`,
+		`{"input":"no-url.example","status_code":200}`,
+	}, "\n")
+	tests := []struct {
+		name     string
+		statuses []int
+		want     []string
+	}{
+		{"every record with a url", nil, []string{"https://example.com", "http://example.com:8080/admin", "https://example.com/old", "https://timeout.example"}},
+		{"status filter", []int{200, 301}, []string{"https://example.com", "https://example.com/old"}},
+		{"failed probes dropped by any status filter", []int{200, 301, 403}, []string{"https://example.com", "http://example.com:8080/admin", "https://example.com/old"}},
+		{"no status matches", []int{500}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			err := httpxParser(tt.statuses)(strings.NewReader(records), func(u string) { got = append(got, u) })
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseGnmap(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{"nmap service detection", "# Nmap 7.94 scan initiated as: nmap -sV -oG - 10.0.0.1\n" +
+			"Host: 10.0.0.1 (example.com)\tStatus: Up\n" +
+			"Host: 10.0.0.1 (example.com)\tPorts: 22/open/tcp//ssh//OpenSSH 9.6/, 80/open/tcp//http//nginx/, 443/open/tcp//ssl|http//nginx/, 8443/open/tcp//https-alt///, 9000/open/tcp//http///\tIgnored State: closed (995)\n" +
+			"# Nmap done at Mon Jan  1 00:00:00 2024 -- 1 IP address (1 host up) scanned in 12.34 seconds",
+			[]string{"http://example.com/", "https://example.com/", "https://example.com:8443/", "http://example.com:9000/"}},
+		{"ports without a service", "Host: 10.0.0.2 ()\tPorts: 80/open/tcp////, 443/open/tcp////, 8080/open/tcp////, 9443/open/tcp////, 3306/open/tcp////",
+			[]string{"http://10.0.0.2/", "https://10.0.0.2/", "http://10.0.0.2:8080/", "https://10.0.0.2:9443/"}},
+		{"detected service overrides the port", "Host: 10.0.0.3 ()\tPorts: 80/open/tcp//ssh///, 8443/open/tcp//http///, 2222/open/tcp//ssl|http///",
+			[]string{"http://10.0.0.3:8443/", "https://10.0.0.3:2222/"}},
+		{"masscan", "# Masscan 1.3.2 scan initiated Mon Jan  1 00:00:00 2024\n" +
+			"Timestamp: 1704067200\tHost: 10.0.0.4 ()\tPorts: 443/open/tcp//https//\n" +
+			"Timestamp: 1704067201\tHost: 10.0.0.4 ()\tPorts: 8080/open/tcp////\n" +
+			"# Masscan done at Mon Jan  1 00:00:01 2024",
+			[]string{"https://10.0.0.4/", "http://10.0.0.4:8080/"}},
+		{"closed, filtered and UDP ports skipped", "Host: 10.0.0.5 ()\tPorts: 80/closed/tcp//http///, 443/filtered/tcp//https///, 80/open/udp//http///, 8080/open|filtered/tcp////",
+			nil},
+		{"malformed ports skipped", "Host: 10.0.0.6 ()\tPorts: http/open/tcp//http///, 80/open, 8000/open/tcp////",
+			[]string{"http://10.0.0.6:8000/"}},
+		{"hosts without ports", "Host: 10.0.0.7 ()\tStatus: Up", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			if err := parseGnmap(strings.NewReader(tt.input), func(u string) { got = append(got, u) }); err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/aleister1102/timetraveller/pkg/timetraveller"
+)
+
+// compareWithLive fetches the live version of a snapshot's original URL and
+// summarises how it differs from the archived body: HTTP status, size delta,
+// whether the content hashes match, and a fuzzy similarity score. The request
+// takes a slot of limiter for the live host, like archive requests do.
+func compareWithLive(client *http.Client, limiter *timetraveller.HostLimiter, originalURL string, archived []byte, maxSize int64) string {
+	req, err := http.NewRequestWithContext(context.Background(), "GET", originalURL, nil)
+	if err != nil {
+		return fmt.Sprintf("Live: invalid URL (%v)", err)
+	}
+	release, err := limiter.Acquire(req.Context(), req.URL.Host)
+	if err != nil {
+		return fmt.Sprintf("Live: aborted (%v)", err)
+	}
+	defer release()
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Sprintf("Live: unreachable (%v)", err)
+	}
+	defer resp.Body.Close()
+
+	var body io.Reader = resp.Body
+	if maxSize > 0 {
+		body = io.LimitReader(resp.Body, maxSize)
+	}
+	live, err := io.ReadAll(body)
+	if err != nil {
+		return fmt.Sprintf("Live: %s, error reading body (%v)", resp.Status, err)
+	}
+
+	delta := len(live) - len(archived)
+	percent := 0.0
+	if len(archived) > 0 {
+		percent = float64(delta) / float64(len(archived)) * 100
+	}
+	hash := "hash differs"
+	if sha256.Sum256(live) == sha256.Sum256(archived) {
+		hash = "hash matches"
+	}
+	return fmt.Sprintf("Live: %s, size %d -> %d (%+.1f%%), %s, similarity %.2f",
+		resp.Status, len(archived), len(live), percent, hash, similarity(archived, live))
+}
+
+// similarity returns the Jaccard similarity (0 to 1) of the word trigrams of a and b.
+func similarity(a, b []byte) float64 {
+	shinglesA, shinglesB := shingles(a), shingles(b)
+	if len(shinglesA) == 0 && len(shinglesB) == 0 {
+		return 1
+	}
+	shared := 0
+	for s := range shinglesA {
+		if shinglesB[s] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(shinglesA)+len(shinglesB)-shared)
+}
+
+// shingles returns the set of word trigrams in text; texts shorter than three
+// words yield their words joined as a single shingle.
+func shingles(text []byte) map[string]bool {
+	words := strings.Fields(string(bytes.ToLower(text)))
+	set := make(map[string]bool)
+	if len(words) < 3 {
+		if len(words) > 0 {
+			set[strings.Join(words, " ")] = true
+		}
+		return set
+	}
+	for i := 0; i+3 <= len(words); i++ {
+		set[strings.Join(words[i:i+3], " ")] = true
+	}
+	return set
+}
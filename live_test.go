@@ -0,0 +1,55 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aleister1102/timetraveller/pkg/timetraveller"
+)
+
+func TestCompareWithLiveSharesHostLimiter(t *testing.T) {
+	tests := []struct {
+		name     string
+		limit    int
+		compares int
+	}{
+		{"one request at a time", 1, 4},
+		{"two requests at a time", 2, 8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var inFlight, peak atomic.Int64
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := inFlight.Add(1)
+				defer inFlight.Add(-1)
+				for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+				}
+				time.Sleep(20 * time.Millisecond)
+				io.WriteString(w, "the live page")
+			}))
+			defer server.Close()
+
+			limiter := timetraveller.NewHostLimiter(tt.limit)
+			var wg sync.WaitGroup
+			for range tt.compares {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if note := compareWithLive(server.Client(), limiter, server.URL, []byte("the live page"), 0); !strings.Contains(note, "hash matches") {
+						t.Errorf("got %q, want a matching hash", note)
+					}
+				}()
+			}
+			wg.Wait()
+			if got := peak.Load(); got > int64(tt.limit) {
+				t.Errorf("%d concurrent requests to the live host, want at most %d", got, tt.limit)
+			}
+		})
+	}
+}
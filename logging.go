@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	// consoleOut is stderr as the program started, so that -silent can
+	// discard other stderr output while errors still show.
+	consoleOut io.Writer = os.Stderr
+	consoleMu  sync.Mutex
+	// consoleErrorsOnly hides every console message below error level.
+	consoleErrorsOnly atomic.Bool
+	// console prints the messages of the tool itself.
+	console = &consoleHandler{level: slog.LevelInfo}
+	// logFile records every message, including the client's, for -log-file.
+	logFile slog.Handler
+)
+
+func init() {
+	slog.SetDefault(slog.New(console))
+	// Fatal errors reported through the log package are errors.
+	slog.SetLogLoggerLevel(slog.LevelError)
+}
+
+// setupLogging applies the -log-file and -log-format flags of fs, if it
+// defines them.
+func setupLogging(fs *flag.FlagSet) error {
+	path := flagValue(fs, "log-file")
+	if path == "" {
+		return nil
+	}
+	format := flagValue(fs, "log-format")
+	if format != "json" && format != "text" {
+		return fmt.Errorf("invalid -log-format %q: expected json or text", format)
+	}
+	file, err := os.OpenFile(expandHome(path), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	opts := &slog.HandlerOptions{Level: slog.LevelInfo}
+	if flagValue(fs, "vv") == "true" {
+		opts.Level = slog.LevelDebug
+	}
+	if format == "json" {
+		logFile = slog.NewJSONHandler(file, opts)
+	} else {
+		logFile = slog.NewTextHandler(file, opts)
+	}
+	slog.SetDefault(slog.New(multiHandler{console, logFile}))
+	return nil
+}
+
+// fileLog records a message in the -log-file only, for errors already shown
+// among the results.
+func fileLog(level slog.Level, msg string, args ...any) {
+	if logFile != nil {
+		slog.New(logFile).Log(context.Background(), level, msg, args...)
+	}
+}
+
+// flagValue returns the value of the named flag of fs, or "" if it has none.
+func flagValue(fs *flag.FlagSet, name string) string {
+	if f := fs.Lookup(name); f != nil {
+		return f.Value.String()
+	}
+	return ""
+}
+
+// clientLogger returns the logger of the lookup client: its messages show on
+// the console from level (nil to hide them) and always go to the -log-file.
+// It returns nil if they go nowhere.
+func clientLogger(level slog.Leveler) *slog.Logger {
+	var handlers multiHandler
+	if level != nil {
+		handlers = append(handlers, &consoleHandler{level: level})
+	}
+	if logFile != nil {
+		handlers = append(handlers, logFile)
+	}
+	if len(handlers) == 0 {
+		return nil
+	}
+	return slog.New(handlers)
+}
+
+// consoleHandler prints log records for people: a colored "[!]" or "[i]"
+// prefix by level, the message and the attributes as key=value pairs.
+type consoleHandler struct {
+	level  slog.Leveler
+	attrs  string // Preformatted attributes added by WithAttrs
+	prefix string // Key prefix of the groups opened by WithGroup
+}
+
+func (h *consoleHandler) Enabled(_ context.Context, level slog.Level) bool {
+	if consoleErrorsOnly.Load() && level < slog.LevelError {
+		return false
+	}
+	return level >= h.level.Level()
+}
+
+func (h *consoleHandler) Handle(_ context.Context, r slog.Record) error {
+	color, prefix := ansiCyan, "[d] "
+	switch {
+	case r.Level >= slog.LevelError:
+		color, prefix = ansiRed, "[!] "
+	case r.Level >= slog.LevelWarn:
+		color, prefix = ansiYellow, "[!] "
+	case r.Level >= slog.LevelInfo:
+		color, prefix = ansiBlue, "[i] "
+	}
+	var b strings.Builder
+	if consoleColor {
+		b.WriteString(color)
+	}
+	b.WriteString(prefix)
+	b.WriteString(r.Message)
+	b.WriteString(h.attrs)
+	r.Attrs(func(a slog.Attr) bool {
+		writeAttr(&b, h.prefix, a)
+		return true
+	})
+	if consoleColor {
+		b.WriteString(ansiReset)
+	}
+	b.WriteString("\n")
+
+	consoleMu.Lock()
+	defer consoleMu.Unlock()
+	_, err := io.WriteString(consoleOut, b.String())
+	return err
+}
+
+func (h *consoleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var b strings.Builder
+	for _, a := range attrs {
+		writeAttr(&b, h.prefix, a)
+	}
+	h2 := *h
+	h2.attrs += b.String()
+	return &h2
+}
+
+func (h *consoleHandler) WithGroup(name string) slog.Handler {
+	h2 := *h
+	h2.prefix += name + "."
+	return &h2
+}
+
+// writeAttr writes a as " key=value", quoting values with spaces.
+func writeAttr(b *strings.Builder, prefix string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		for _, member := range a.Value.Group() {
+			writeAttr(b, prefix+a.Key+".", member)
+		}
+		return
+	}
+	value := a.Value.String()
+	if value == "" || strings.ContainsAny(value, " \t\"=") {
+		value = strconv.Quote(value)
+	}
+	fmt.Fprintf(b, " %s%s=%s", prefix, a.Key, value)
+}
+
+// multiHandler passes every record to each of its handlers that takes it.
+type multiHandler []slog.Handler
+
+func (m multiHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range m {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m multiHandler) Handle(ctx context.Context, r slog.Record) error {
+	var err error
+	for _, h := range m {
+		if h.Enabled(ctx, r.Level) {
+			if handleErr := h.Handle(ctx, r.Clone()); err == nil {
+				err = handleErr
+			}
+		}
+	}
+	return err
+}
+
+func (m multiHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make(multiHandler, len(m))
+	for i, h := range m {
+		handlers[i] = h.WithAttrs(attrs)
+	}
+	return handlers
+}
+
+func (m multiHandler) WithGroup(name string) slog.Handler {
+	handlers := make(multiHandler, len(m))
+	for i, h := range m {
+		handlers[i] = h.WithGroup(name)
+	}
+	return handlers
+}
+
+// bridgeLog sends the output of the log package to the default slog logger
+// again, after something replaced it with log.SetOutput.
+func bridgeLog() {
+	slog.SetDefault(slog.Default())
+}
@@ -1,135 +1,153 @@
 package main
 
 import (
-	"bufio"
 	"flag"
 	"fmt"
 	"log"
-	"net/http"
+	"log/slog"
 	"os"
-	"strings"
-	"sync"
-	"time"
 )
 
-var (
-	numWorkersFlag       *int
-	requestTimeoutMsFlag *int
-	noErrorFilterFlag    *bool
-	delayMsFlag          *int
-	latestSnapshotFlag   *bool
-	outputFileFlag       *string
+// ANSI escape codes of the colors.
+const (
+	ansiReset  = "\033[0m"
+	ansiRed    = "\033[31m"
+	ansiGreen  = "\033[32m"
+	ansiYellow = "\033[33m"
+	ansiBlue   = "\033[34m"
+	ansiCyan   = "\033[36m"
 )
 
-func main() {
-	numWorkersFlag = flag.Int("t", 10, "Number of concurrent goroutines (threads)")
-	requestTimeoutMsFlag = flag.Int("to", 60000, "Timeout for each HTTP request in milliseconds")
-	noErrorFilterFlag = flag.Bool("no-err", false, "Filter out 'not found' and error results")
-	delayMsFlag = flag.Int("d", 0, "Delay in milliseconds between each request sent by a worker")
-	latestSnapshotFlag = flag.Bool("latest", false, "Get the latest snapshot instead of the oldest")
-	outputFileFlag = flag.String("o", "", "File to write found snapshot URLs to")
+// ANSI Color Codes of the output, emptied by setupColor when stdout gets no
+// color.
+var (
+	ColorReset  = ansiReset
+	ColorRed    = ansiRed
+	ColorGreen  = ansiGreen
+	ColorYellow = ansiYellow
+	ColorBlue   = ansiBlue
+	ColorCyan   = ansiCyan
+)
 
-	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Usage: timetraveller [options] <url1> [url2 ...]\n")
-		fmt.Fprintf(os.Stderr, "Options:\n")
-		flag.PrintDefaults()
-		fmt.Fprintf(os.Stderr, "\nOr pipe URLs:\n")
-		fmt.Fprintf(os.Stderr, "  echo <url> | timetraveller [options]\n")
-		fmt.Fprintf(os.Stderr, "  cat list_of_urls.txt | timetraveller [options]\n")
+// consoleColor reports whether the messages on stderr are colored.
+var consoleColor = true
+
+// setupColor applies the -color and -no-color flags of fs, if it defines
+// them. By default, output is colored only on a terminal and when the
+// NO_COLOR environment variable is unset, each of stdout and stderr deciding
+// for itself.
+func setupColor(fs *flag.FlagSet) error {
+	mode := flagValue(fs, "color")
+	if flagValue(fs, "no-color") == "true" {
+		mode = "never"
 	}
-	flag.Parse()
-
-	urlsToCheck := flag.Args()
-
-	// Read from stdin if no args are provided and data is piped
-	stat, _ := os.Stdin.Stat()
-	if len(urlsToCheck) == 0 && (stat.Mode()&os.ModeCharDevice) == 0 {
-		scanner := bufio.NewScanner(os.Stdin)
-		for scanner.Scan() {
-			line := strings.TrimSpace(scanner.Text())
-			if line != "" {
-				urlsToCheck = append(urlsToCheck, line)
-			}
-		}
-		if err := scanner.Err(); err != nil {
-			log.Fatalf("Error reading from stdin: %v", err)
+	useColor := func(file *os.File) bool {
+		switch mode {
+		case "always":
+			return true
+		case "never":
+			return false
 		}
+		return os.Getenv("NO_COLOR") == "" && isTerminal(file)
 	}
-
-	if len(urlsToCheck) == 0 {
-		// Banner is already printed. Now print usage.
-		flag.Usage()
-		os.Exit(1)
+	switch mode {
+	case "", "auto", "always", "never":
+	default:
+		return fmt.Errorf("invalid -color %q: expected auto, always or never", mode)
 	}
-
-	httpClient := &http.Client{
-		Timeout: time.Duration(*requestTimeoutMsFlag) * time.Millisecond,
+	if !useColor(os.Stdout) {
+		ColorReset, ColorRed, ColorGreen, ColorYellow, ColorBlue, ColorCyan = "", "", "", "", "", ""
 	}
+	consoleColor = useColor(os.Stderr)
+	return nil
+}
 
-	jobs := make(chan string, len(urlsToCheck))
-	resultsChan := make(chan ProcessResult, len(urlsToCheck))
-	var wg sync.WaitGroup
+// Exit codes of the commands.
+const (
+	exitFound    = 0 // check and watch found a snapshot
+	exitNotFound = 1 // check and watch found nothing
+	exitUsage    = 2 // Invalid flags or arguments, as with flag.ExitOnError
+	exitAborted  = 3 // Every lookup failed: the archive kept failing
+	exitFailed   = 4 // A fatal error, such as an unwritable output file, stopped the command
+)
 
-	// Start workers
-	for i := 0; i < *numWorkersFlag; i++ {
-		wg.Add(1)
-		go worker(i+1, httpClient, jobs, resultsChan, &wg, *delayMsFlag, 3, 5000)
-	}
+// usageFatalf reports an invalid use of a command and exits with exitUsage.
+func usageFatalf(format string, args ...any) {
+	slog.Error(fmt.Sprintf(format, args...))
+	os.Exit(exitUsage)
+}
 
-	// Send jobs
-	for _, u := range urlsToCheck {
-		jobs <- u
-	}
-	close(jobs)
+// fatalf reports an error that stops a command and exits with exitFailed, so
+// it is never mistaken for a run that found nothing. Like log.Fatalf, it goes
+// through the log package, which the -tui dashboard captures.
+func fatalf(format string, args ...any) {
+	log.Printf(format, args...)
+	os.Exit(exitFailed)
+}
 
-	go func() {
-		wg.Wait()
-		close(resultsChan)
-	}()
+// silenceStderr discards the informational messages written to stderr, for
+// -silent. Errors still show, and -log-file still records everything.
+func silenceStderr() {
+	consoleErrorsOnly.Store(true)
+	if devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0); err == nil {
+		os.Stderr = devNull
+	}
+}
 
-	var foundSnapshotURLs []string
+// command is a timetraveller subcommand with its own flag set.
+type command struct {
+	name    string
+	summary string
+	run     func(args []string)
+}
 
-	// Process and print results
-	for result := range resultsChan {
-		if *noErrorFilterFlag {
-			if result.Error != nil {
-				continue
-			}
-			if result.Status == "not found" {
-				continue
-			}
-		}
+var commands []command
+
+func init() {
+	commands = []command{
+		{"check", "Find the oldest or latest snapshot of each URL (default)", runCheck},
+		{"fetch", "Download the archived content of snapshots", runFetch},
+		{"diff", "Show how a page changed between two snapshots", runDiff},
+		{"endpoints", "Extract endpoints from archived JavaScript files", runEndpoints},
+		{"robots", "List the Disallow paths of archived robots.txt files", runRobots},
+		{"sitemap", "List the URLs of archived sitemap.xml files", runSitemap},
+		{"subs", "Enumerate archived subdomains of each domain", runSubs},
+		{"urls", "Harvest every unique archived URL of each domain", runURLs},
+		{"watch", "Report URLs that gained new captures since the last run", runWatch},
+		{"serve", "Serve lookups over an HTTP API", runServe},
+		{"mcp", "Serve lookups to AI assistants over the Model Context Protocol", runMCP},
+	}
+}
 
-		var outputLine string
-		label := "Oldest:"
-		if *latestSnapshotFlag {
-			label = "Latest:"
-		}
+func main() {
+	args := os.Args[1:]
+	if len(args) > 0 && args[0] == "help" {
+		printUsage()
+		return
+	}
+	cmd, args := commandFor(args)
+	cmd.run(args)
+}
 
-		if result.Error != nil {
-			outputLine = fmt.Sprintf(ColorRed+"[!] %s - %v"+ColorReset,
-				result.URL, result.Error)
-		} else {
-			switch result.Status {
-			case "found":
-				outputLine = fmt.Sprintf(ColorGreen+"[+] %s - Snapshots: %d - %s %s"+ColorReset,
-					result.URL, result.SnapshotCount, label, result.OldestURL)
-				foundSnapshotURLs = append(foundSnapshotURLs, result.OldestURL)
-			case "not found":
-				outputLine = fmt.Sprintf(ColorYellow+"[-] %s"+ColorReset,
-					result.URL)
-			default:
-				outputLine = fmt.Sprintf(ColorCyan+"[i] %s - Status: %s (Unknown)"+ColorReset,
-					result.URL, result.Status)
+// commandFor returns the command named by the first of args, with the
+// arguments left for it. Without a known subcommand, it returns "check" with
+// every argument, for backward compatibility.
+func commandFor(args []string) (command, []string) {
+	if len(args) > 0 {
+		for _, cmd := range commands {
+			if cmd.name == args[0] {
+				return cmd, args[1:]
 			}
 		}
-		fmt.Println(outputLine)
 	}
+	return commands[0], args
+}
 
-	if *outputFileFlag != "" && len(foundSnapshotURLs) > 0 {
-		if err := writeUrlsToFile(*outputFileFlag, foundSnapshotURLs); err != nil {
-			log.Fatalf("Error writing to output file: %v", err)
-		}
-		fmt.Printf(ColorBlue+"\n[i] Successfully wrote %d found URLs to %s\n"+ColorReset, len(foundSnapshotURLs), *outputFileFlag)
+func printUsage() {
+	fmt.Fprintf(os.Stderr, "Usage: timetraveller <command> [options] <url1> [url2 ...]\n")
+	fmt.Fprintf(os.Stderr, "\nCommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", cmd.name, cmd.summary)
 	}
+	fmt.Fprintf(os.Stderr, "\nRun 'timetraveller <command> -h' for the options of a command.\n")
 }
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestCommandFor(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		wantCmd  string
+		wantArgs []string
+	}{
+		{"no arguments", nil, "check", nil},
+		{"explicit check", []string{"check", "-latest", "https://a.example/"}, "check", []string{"-latest", "https://a.example/"}},
+		{"subcommand", []string{"fetch", "-dir", "out", "https://a.example/"}, "fetch", []string{"-dir", "out", "https://a.example/"}},
+		{"subcommand without arguments", []string{"mcp"}, "mcp", nil},
+		{"bare URL runs check", []string{"https://a.example/"}, "check", []string{"https://a.example/"}},
+		{"flags before the URL run check", []string{"-latest", "https://a.example/"}, "check", []string{"-latest", "https://a.example/"}},
+		{"command name matched exactly", []string{"Fetch", "https://a.example/"}, "check", []string{"Fetch", "https://a.example/"}},
+		{"command name only as first argument", []string{"https://a.example/", "urls"}, "check", []string{"https://a.example/", "urls"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd, args := commandFor(tt.args)
+			if cmd.name != tt.wantCmd {
+				t.Errorf("command %q, want %q", cmd.name, tt.wantCmd)
+			}
+			if !slices.Equal(args, tt.wantArgs) {
+				t.Errorf("args %q, want %q", args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestCheckFlags(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		check    func(f *checkFlags) bool
+		wantURLs []string
+	}{
+		{"defaults", []string{"https://a.example/"},
+			func(f *checkFlags) bool { return !f.latestSnapshot && f.numWorkers > 0 && f.format == "" },
+			[]string{"https://a.example/"}},
+		{"check flags", []string{"-latest", "-all", "https://a.example/", "https://b.example/"},
+			func(f *checkFlags) bool { return f.latestSnapshot && f.allSnapshots },
+			[]string{"https://a.example/", "https://b.example/"}},
+		{"engine flags", []string{"-t", "7", "-retries", "1", "-cdx-url", "http://localhost:8080/cdx", "https://a.example/"},
+			func(f *checkFlags) bool {
+				return f.numWorkers == 7 && f.retries == 1 && f.cdxURL == "http://localhost:8080/cdx"
+			},
+			[]string{"https://a.example/"}},
+		{"no URLs", []string{"-latest"},
+			func(f *checkFlags) bool { return f.latestSnapshot },
+			nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var f checkFlags
+			fs := flag.NewFlagSet("check", flag.ContinueOnError)
+			f.register(fs)
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			if !tt.check(&f) {
+				t.Errorf("unexpected flags parsed from %q", tt.args)
+			}
+			if got := fs.Args(); !slices.Equal(got, tt.wantURLs) {
+				t.Errorf("URLs %q, want %q", got, tt.wantURLs)
+			}
+		})
+	}
+}
+
+func TestCheckExitCodes(t *testing.T) {
+	if args := os.Getenv("TIMETRAVELLER_TEST_CHECK"); args != "" {
+		runCheck(strings.Split(args, " "))
+		return
+	}
+	dir := t.TempDir()
+	corrupt := filepath.Join(dir, "state.json")
+	if err := os.WriteFile(corrupt, []byte("{not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		args string
+		want int
+	}{
+		{"invalid flag value", "-input-format xml https://a.example/", exitUsage},
+		{"corrupt state file", "-no-progress -state " + corrupt + " https://a.example/", exitFailed},
+		{"unwritable output file", "-no-progress -o " + filepath.Join(dir, "missing", "out.txt") + " https://a.example/", exitFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := exec.Command(os.Args[0], "-test.run=^TestCheckExitCodes$")
+			cmd.Env = append(os.Environ(), "TIMETRAVELLER_TEST_CHECK="+tt.args)
+			err := cmd.Run()
+			var exitErr *exec.ExitError
+			if !errors.As(err, &exitErr) || exitErr.ExitCode() != tt.want {
+				t.Errorf("exited with %v, want code %d", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"runtime/debug"
+	"slices"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/aleister1102/timetraveller/pkg/timetraveller"
+)
+
+// mcpProtocolVersion is the Model Context Protocol revision spoken by "mcp".
+const mcpProtocolVersion = "2025-06-18"
+
+// mcpMaxDownload bounds the snapshot bodies fetch_snapshot_content downloads.
+const mcpMaxDownload = 10 << 20
+
+// runMCP serves the lookup engine to AI assistants as a Model Context Protocol
+// server: JSON-RPC messages, one per line, on stdin and stdout.
+func runMCP(args []string) {
+	var f engineFlags
+	fs := flag.NewFlagSet("mcp", flag.ExitOnError)
+	f.registerWorkers(fs)
+	f.registerLookup(fs)
+	f.registerOutput(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: timetraveller mcp [options]\n")
+		fmt.Fprintf(os.Stderr, "Serves the tools find_oldest_snapshot, list_snapshots and fetch_snapshot_content over MCP on stdin and stdout.\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	parseFlags(fs, args)
+	if fs.NArg() > 0 {
+		fs.Usage()
+		os.Exit(exitUsage)
+	}
+	opts, err := f.lookupOptions()
+	if err != nil {
+		usageFatalf("%v", err)
+	}
+	s := &mcpServer{f: &f, opts: opts, out: json.NewEncoder(os.Stdout)}
+	if err := s.serve(os.Stdin); err != nil {
+		fatalf("Error reading MCP messages: %v", err)
+	}
+}
+
+// mcpServer answers MCP requests. Tool calls run concurrently, sharing the
+// client and its limits.
+type mcpServer struct {
+	f    *engineFlags
+	opts timetraveller.Options
+
+	mu  sync.Mutex // Serializes writes to out
+	out *json.Encoder
+	wg  sync.WaitGroup
+}
+
+// mcpMessage is a JSON-RPC 2.0 request or notification.
+type mcpMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"` // Absent in notifications
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// mcpResponse is a JSON-RPC 2.0 response.
+type mcpResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *mcpError       `json:"error,omitempty"`
+}
+
+type mcpError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// JSON-RPC error codes.
+const (
+	mcpParseError     = -32700
+	mcpMethodNotFound = -32601
+	mcpInvalidParams  = -32602
+)
+
+// mcpTool describes a tool in tools/list.
+type mcpTool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	InputSchema map[string]any `json:"inputSchema"`
+}
+
+// mcpTools are the tools the server offers.
+var mcpTools = []mcpTool{
+	{
+		Name:        "find_oldest_snapshot",
+		Description: "Find the oldest archived snapshot of a URL (or the latest, with latest=true) and how many captures the archive holds.",
+		InputSchema: mcpSchema(map[string]any{
+			"url":    map[string]any{"type": "string", "description": "URL to look up, e.g. example.com/login"},
+			"latest": map[string]any{"type": "boolean", "description": "Find the latest snapshot instead of the oldest"},
+		}, "url"),
+	},
+	{
+		Name:        "list_snapshots",
+		Description: "List the archived captures of a URL in capture-time order, optionally between two dates.",
+		InputSchema: mcpSchema(map[string]any{
+			"url":   map[string]any{"type": "string", "description": "URL to look up"},
+			"from":  map[string]any{"type": "string", "description": "Earliest capture date as YYYY, YYYYMM or YYYYMMDD"},
+			"to":    map[string]any{"type": "string", "description": "Latest capture date as YYYY, YYYYMM or YYYYMMDD"},
+			"limit": map[string]any{"type": "integer", "description": "Most captures to return, oldest first (default 100)"},
+		}, "url"),
+	},
+	{
+		Name:        "fetch_snapshot_content",
+		Description: "Fetch the archived content of a URL as originally served: the capture closest to timestamp, or the oldest. Binary content is refused.",
+		InputSchema: mcpSchema(map[string]any{
+			"url":       map[string]any{"type": "string", "description": "URL whose capture to fetch"},
+			"timestamp": map[string]any{"type": "string", "description": "Pick the capture closest to this time, as YYYYMMDDhhmmss or a prefix of it"},
+			"max_bytes": map[string]any{"type": "integer", "description": "Truncate the content to this many bytes (default 100000)"},
+		}, "url"),
+	},
+}
+
+// mcpSchema returns the JSON schema of an object with properties, of which
+// required must be given.
+func mcpSchema(properties map[string]any, required ...string) map[string]any {
+	return map[string]any{"type": "object", "properties": properties, "required": required}
+}
+
+// serve handles the messages read from r until it ends, then waits for the
+// tool calls in progress.
+func (s *mcpServer) serve(r io.Reader) error {
+	defer s.wg.Wait()
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxRequestBody)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var msg mcpMessage
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			s.reply(mcpResponse{ID: json.RawMessage("null"), Error: &mcpError{mcpParseError, err.Error()}})
+			continue
+		}
+		if len(msg.ID) == 0 {
+			continue // Notifications, such as notifications/initialized, need no answer.
+		}
+		switch msg.Method {
+		case "initialize":
+			version := "(devel)"
+			if info, ok := debug.ReadBuildInfo(); ok {
+				version = info.Main.Version
+			}
+			s.reply(mcpResponse{ID: msg.ID, Result: map[string]any{
+				"protocolVersion": mcpProtocolVersion,
+				"capabilities":    map[string]any{"tools": map[string]any{}},
+				"serverInfo":      map[string]any{"name": "timetraveller", "version": version},
+			}})
+		case "ping":
+			s.reply(mcpResponse{ID: msg.ID, Result: map[string]any{}})
+		case "tools/list":
+			s.reply(mcpResponse{ID: msg.ID, Result: map[string]any{"tools": mcpTools}})
+		case "tools/call":
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				s.callTool(msg)
+			}()
+		default:
+			s.reply(mcpResponse{ID: msg.ID, Error: &mcpError{mcpMethodNotFound, "method not found: " + msg.Method}})
+		}
+	}
+	return scanner.Err()
+}
+
+// reply writes a response.
+func (s *mcpServer) reply(resp mcpResponse) {
+	resp.JSONRPC = "2.0"
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.out.Encode(resp)
+}
+
+// mcpToolArgs are the arguments of every tool.
+type mcpToolArgs struct {
+	URL       string `json:"url"`
+	Latest    bool   `json:"latest"`
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Limit     int    `json:"limit"`
+	Timestamp string `json:"timestamp"`
+	MaxBytes  int    `json:"max_bytes"`
+}
+
+// callTool answers a tools/call request. Failed lookups are tool results
+// flagged isError, so the assistant sees why.
+func (s *mcpServer) callTool(msg mcpMessage) {
+	var params struct {
+		Name      string      `json:"name"`
+		Arguments mcpToolArgs `json:"arguments"`
+	}
+	if err := json.Unmarshal(msg.Params, &params); err != nil {
+		s.reply(mcpResponse{ID: msg.ID, Error: &mcpError{mcpInvalidParams, err.Error()}})
+		return
+	}
+	if !slices.ContainsFunc(mcpTools, func(tool mcpTool) bool { return tool.Name == params.Name }) {
+		s.reply(mcpResponse{ID: msg.ID, Error: &mcpError{mcpInvalidParams, "unknown tool: " + params.Name}})
+		return
+	}
+	args := params.Arguments
+	if err := validateInputURL(args.URL); err != nil {
+		s.reply(mcpResponse{ID: msg.ID, Result: mcpText(fmt.Sprintf("invalid url %q: %v", args.URL, err), true)})
+		return
+	}
+	args.URL = timetraveller.PunycodeURL(args.URL)
+
+	ctx := s.f.shutdown().requests
+	var text string
+	var err error
+	switch params.Name {
+	case "find_oldest_snapshot":
+		text, err = s.findOldest(ctx, args)
+	case "list_snapshots":
+		text, err = s.listSnapshots(ctx, args)
+	case "fetch_snapshot_content":
+		text, err = s.fetchContent(ctx, args)
+	}
+	if err != nil {
+		s.reply(mcpResponse{ID: msg.ID, Result: mcpText(err.Error(), true)})
+		return
+	}
+	s.reply(mcpResponse{ID: msg.ID, Result: mcpText(text, false)})
+}
+
+// mcpText returns a tool result holding text.
+func mcpText(text string, isError bool) map[string]any {
+	return map[string]any{
+		"content": []map[string]any{{"type": "text", "text": text}},
+		"isError": isError,
+	}
+}
+
+// lookup looks up url with opts, failing if the lookup does.
+func (s *mcpServer) lookup(ctx context.Context, url string, opts timetraveller.Options) (timetraveller.ProcessResult, error) {
+	result := s.f.client().Lookup(ctx, url, opts)
+	return result, result.Error
+}
+
+// findOldest is the find_oldest_snapshot tool.
+func (s *mcpServer) findOldest(ctx context.Context, args mcpToolArgs) (string, error) {
+	opts := s.opts
+	opts.Latest = args.Latest
+	result, err := s.lookup(ctx, args.URL, opts)
+	if err != nil {
+		return "", err
+	}
+	return mcpJSON(newJSONResult(result, false, false))
+}
+
+// listSnapshots is the list_snapshots tool.
+func (s *mcpServer) listSnapshots(ctx context.Context, args mcpToolArgs) (string, error) {
+	opts := s.opts
+	for _, bound := range []string{args.From, args.To} {
+		if bound != "" && !isDigits(bound) {
+			return "", fmt.Errorf("invalid date %q: expected YYYY, YYYYMM or YYYYMMDD", bound)
+		}
+	}
+	if args.From != "" {
+		opts.From = args.From
+	}
+	if args.To != "" {
+		opts.To = args.To
+	}
+	result, err := s.lookup(ctx, args.URL, opts)
+	if err != nil {
+		return "", err
+	}
+	limit := args.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+	out := newJSONResult(result, true, false)
+	if len(out.Snapshots) > limit {
+		out.Snapshots = out.Snapshots[:limit]
+	}
+	return mcpJSON(out)
+}
+
+// fetchContent is the fetch_snapshot_content tool.
+func (s *mcpServer) fetchContent(ctx context.Context, args mcpToolArgs) (string, error) {
+	opts := s.opts
+	opts.Closest = args.Timestamp
+	opts.Raw = true
+	result, err := s.lookup(ctx, args.URL, opts)
+	if err != nil {
+		return "", err
+	}
+	if result.Status != timetraveller.StatusFound {
+		return "", fmt.Errorf("no snapshot of %s found", args.URL)
+	}
+	body, err := s.f.client().Download(ctx, result.Chosen, mcpMaxDownload, opts)
+	if err != nil {
+		return "", fmt.Errorf("downloading %s: %w", result.Chosen.ArchiveURL(), err)
+	}
+	if !utf8.Valid(body) {
+		return "", fmt.Errorf("the capture of %s is binary (%s)", args.URL, result.Chosen.Field(timetraveller.FieldMimetype))
+	}
+	maxBytes := args.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = 100000
+	}
+	header := fmt.Sprintf("Snapshot: %s\nCaptured: %s\n", result.Chosen.ArchiveURL(), result.Chosen.Field(timetraveller.FieldTimestamp))
+	if len(body) > maxBytes {
+		// Cut at a rune boundary.
+		cut := maxBytes
+		for cut > 0 && !utf8.RuneStart(body[cut]) {
+			cut--
+		}
+		header += fmt.Sprintf("Truncated: first %d of %d bytes\n", cut, len(body))
+		body = body[:cut]
+	}
+	return header + "\n" + string(body), nil
+}
+
+// mcpJSON returns v as indented JSON.
+func mcpJSON(v any) (string, error) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	return string(data), err
+}
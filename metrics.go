@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aleister1102/timetraveller/pkg/timetraveller"
+)
+
+// queryBuckets are the upper bounds, in seconds, of the provider query
+// latency histogram.
+var queryBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120}
+
+// metrics counts what a run does for Prometheus, served on /metrics in the
+// text exposition format. A nil metrics counts nothing.
+type metrics struct {
+	client *timetraveller.Client
+
+	mu      sync.Mutex
+	results map[string]int64         // By result status
+	queries map[string]*queryLatency // By provider
+}
+
+// queryLatency is the latency histogram of one provider's queries.
+type queryLatency struct {
+	buckets []int64 // Cumulative counts, by queryBuckets
+	count   int64
+	sum     float64
+	errors  int64
+}
+
+// newMetrics returns metrics of the lookups of client, which it hooks into.
+func newMetrics(client *timetraveller.Client) *metrics {
+	m := &metrics{
+		client:  client,
+		results: make(map[string]int64),
+		queries: make(map[string]*queryLatency),
+	}
+	client.OnQuery = m.queried
+	return m
+}
+
+// serve serves /metrics on addr until the program exits.
+func (m *metrics) serve(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		m.write(w)
+	})
+	slog.Info("Serving metrics", "url", "http://"+listener.Addr().String()+"/metrics")
+	go http.Serve(listener, mux)
+	return nil
+}
+
+// queried records a provider query; it is a Client.OnQuery hook.
+func (m *metrics) queried(provider string, elapsed time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	latency := m.queries[provider]
+	if latency == nil {
+		latency = &queryLatency{buckets: make([]int64, len(queryBuckets))}
+		m.queries[provider] = latency
+	}
+	seconds := elapsed.Seconds()
+	for i, bound := range queryBuckets {
+		if seconds <= bound {
+			latency.buckets[i]++
+		}
+	}
+	latency.count++
+	latency.sum += seconds
+	if err != nil {
+		latency.errors++
+	}
+}
+
+// count passes on the results of in, counting them by status.
+func (m *metrics) count(in <-chan timetraveller.ProcessResult) <-chan timetraveller.ProcessResult {
+	if m == nil {
+		return in
+	}
+	out := make(chan timetraveller.ProcessResult)
+	go func() {
+		defer close(out)
+		for result := range in {
+			m.mu.Lock()
+			m.results[result.Status]++
+			m.mu.Unlock()
+			out <- result
+		}
+	}()
+	return out
+}
+
+// write writes every metric in the Prometheus text format.
+func (m *metrics) write(w io.Writer) {
+	stats := m.client.Stats()
+	counter := func(name, help string, value int64) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n%s %d\n", name, help, name, name, value)
+	}
+	counter("timetraveller_requests_total", "Requests sent to archives, retries included.", stats.Requests)
+	counter("timetraveller_retries_total", "Requests repeating a failed one.", stats.Retries)
+	counter("timetraveller_rate_limited_total", "Rate-limited responses from archives.", stats.RateLimited)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	fmt.Fprint(w, "# HELP timetraveller_results_total Lookup results by status.\n# TYPE timetraveller_results_total counter\n")
+	for _, status := range []string{timetraveller.StatusFound, timetraveller.StatusNotFound, timetraveller.StatusError} {
+		fmt.Fprintf(w, "timetraveller_results_total{status=%q} %d\n", status, m.results[status])
+	}
+
+	providers := slices.Sorted(maps.Keys(m.queries))
+	fmt.Fprint(w, "# HELP timetraveller_provider_query_duration_seconds Duration of the queries to each archive provider, paging and retries included.\n# TYPE timetraveller_provider_query_duration_seconds histogram\n")
+	for _, provider := range providers {
+		latency := m.queries[provider]
+		for i, bound := range queryBuckets {
+			fmt.Fprintf(w, "timetraveller_provider_query_duration_seconds_bucket{provider=%q,le=%q} %d\n",
+				provider, strconv.FormatFloat(bound, 'g', -1, 64), latency.buckets[i])
+		}
+		fmt.Fprintf(w, "timetraveller_provider_query_duration_seconds_bucket{provider=%q,le=\"+Inf\"} %d\n", provider, latency.count)
+		fmt.Fprintf(w, "timetraveller_provider_query_duration_seconds_sum{provider=%q} %g\n", provider, latency.sum)
+		fmt.Fprintf(w, "timetraveller_provider_query_duration_seconds_count{provider=%q} %d\n", provider, latency.count)
+	}
+	fmt.Fprint(w, "# HELP timetraveller_provider_query_errors_total Failed queries to each archive provider.\n# TYPE timetraveller_provider_query_errors_total counter\n")
+	for _, provider := range providers {
+		fmt.Fprintf(w, "timetraveller_provider_query_errors_total{provider=%q} %d\n", provider, m.queries[provider].errors)
+	}
+}
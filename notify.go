@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aleister1102/timetraveller/pkg/timetraveller"
+)
+
+// discordMaxContent is the longest message Discord accepts.
+const discordMaxContent = 2000
+
+// notifyTarget is a chat channel given with -notify as slack:// or discord://
+// followed by its incoming webhook URL without the scheme.
+type notifyTarget struct {
+	service string // "slack" or "discord"
+	url     string
+}
+
+// notifyFlag collects the values of the repeatable -notify flag.
+type notifyFlag []notifyTarget
+
+func (n *notifyFlag) String() string {
+	targets := make([]string, len(*n))
+	for i, target := range *n {
+		// Webhook URLs embed their token.
+		targets[i] = target.service + "://"
+	}
+	return strings.Join(targets, ",")
+}
+
+func (n *notifyFlag) Set(value string) error {
+	u, err := url.Parse(value)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("expected slack:// or discord:// followed by the webhook URL, e.g. slack://hooks.slack.com/services/T000/B000/XXXX")
+	}
+	service := u.Scheme
+	if service != "slack" && service != "discord" {
+		return fmt.Errorf("unknown service %q: expected slack or discord", service)
+	}
+	u.Scheme = "https"
+	*n = append(*n, notifyTarget{service: service, url: u.String()})
+	return nil
+}
+
+// notifier posts the summary of a run, and with -notify-findings each finding,
+// to a chat channel. Deliveries are retried like -webhook ones.
+type notifier struct {
+	service string
+	hook    *webhookSink
+}
+
+// newNotifiers returns a notifier for each -notify channel.
+func (f *checkFlags) newNotifiers() []*notifier {
+	notifiers := make([]*notifier, len(f.notify))
+	for i, target := range f.notify {
+		hook := f.newWebhookSink(target.url, true)
+		hook.name = target.service
+		notifiers[i] = &notifier{service: target.service, hook: hook}
+	}
+	return notifiers
+}
+
+// Write posts a found result as a finding.
+func (n *notifier) Write(r checkResult) error {
+	if r.Status != timetraveller.StatusFound {
+		return nil
+	}
+	text := fmt.Sprintf("%s %s - Snapshots: %d - %s", n.bold("Found:"), r.URL, r.SnapshotCount, r.OldestURL)
+	if r.Change != "" {
+		text += " - Change: " + r.Change
+	}
+	n.send(text)
+	return nil
+}
+
+func (n *notifier) Flush() error { return nil }
+func (n *notifier) Close() error { return nil }
+
+// summary posts the totals of a finished run.
+func (n *notifier) summary(s runSummary, interrupted bool) {
+	title := "timetraveller run finished"
+	if interrupted {
+		title = "timetraveller run stopped early"
+	}
+	lines := []string{
+		n.bold(title),
+		fmt.Sprintf("Processed: %d | Found: %d | Not found: %d | Errors: %d", s.Processed, s.Found, s.NotFound, s.Errors),
+		fmt.Sprintf("Snapshots: %d | Requests: %d | Rate limited: %d | Elapsed: %s",
+			s.Snapshots, s.Requests, s.RateLimited, time.Duration(s.ElapsedSeconds*float64(time.Second)).Round(time.Millisecond)),
+	}
+	if len(s.ErrorKinds) > 0 {
+		kinds := slices.Sorted(maps.Keys(s.ErrorKinds))
+		breakdown := make([]string, len(kinds))
+		for i, kind := range kinds {
+			breakdown[i] = fmt.Sprintf("%s: %d", kind, s.ErrorKinds[kind])
+		}
+		lines = append(lines, "Error kinds: "+strings.Join(breakdown, ", "))
+	}
+	n.send(strings.Join(lines, "\n"))
+}
+
+// bold marks text as bold in the service's markdown.
+func (n *notifier) bold(text string) string {
+	if n.service == "discord" {
+		return "**" + text + "**"
+	}
+	return "*" + text + "*"
+}
+
+// send posts a message in the payload the service expects.
+func (n *notifier) send(text string) {
+	if n.service == "discord" {
+		if len(text) > discordMaxContent {
+			text = text[:discordMaxContent-3] + "..."
+		}
+		n.hook.post(map[string]string{"content": text}, 1)
+		return
+	}
+	n.hook.post(map[string]string{"text": text}, 1)
+}
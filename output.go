@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/aleister1102/timetraveller/pkg/timetraveller"
+)
+
+// jsonResult is the JSON representation of a ProcessResult.
+type jsonResult struct {
+	URL           string `json:"url"`
+	Status        string `json:"status"`
+	SnapshotCount int    `json:"snapshot_count"`
+	SnapshotURL   string `json:"snapshot_url,omitempty"`
+	DetailsURL    string `json:"details_url,omitempty"`
+	WARCFile      string `json:"warc_file,omitempty"`   // WARC file holding a Common Crawl capture
+	WARCOffset    string `json:"warc_offset,omitempty"` // Byte offset of its record in WARCFile
+	Error         string `json:"error,omitempty"`
+	SavedURL      string `json:"saved_url,omitempty"`  // Capture created by -save-missing
+	SaveError     string `json:"save_error,omitempty"` // Why -save-missing failed
+	Change        string `json:"change,omitempty"`     // How the result differs from the -diff-run run
+	// ExpectedArchiveURL and Match compare the result with -verify-map;
+	// only filled for the URLs it lists.
+	ExpectedArchiveURL string `json:"expected_archive_url,omitempty"`
+	Match              *bool  `json:"match,omitempty"`
+	// Snapshots lists every capture; only filled in -all mode.
+	Snapshots []jsonSnapshot `json:"snapshots,omitempty"`
+	// Changes lists the captures where the content changed; only filled in -changes mode.
+	Changes []jsonSnapshot `json:"changes,omitempty"`
+}
+
+// jsonSnapshot is a single capture in the JSON output.
+type jsonSnapshot struct {
+	Timestamp  string `json:"timestamp"`
+	URL        string `json:"url"`
+	Digest     string `json:"digest,omitempty"`
+	Archive    string `json:"archive"`
+	WARCFile   string `json:"warc_file,omitempty"`
+	WARCOffset string `json:"warc_offset,omitempty"`
+}
+
+func newJSONResult(r timetraveller.ProcessResult, all, changes bool) jsonResult {
+	out := jsonResult{
+		URL:           r.URL,
+		Status:        r.Status,
+		SnapshotCount: r.SnapshotCount,
+		SnapshotURL:   r.OldestURL,
+		DetailsURL:    r.DetailsURL,
+		WARCFile:      r.Chosen.Field(timetraveller.FieldWARCFile),
+		WARCOffset:    r.Chosen.Field(timetraveller.FieldWARCOffset),
+	}
+	if r.Error != nil {
+		out.Error = r.Error.Error()
+	}
+	if all {
+		for _, entry := range r.Snapshots {
+			out.Snapshots = append(out.Snapshots, jsonSnapshot{
+				Timestamp:  entry.Field(timetraveller.FieldTimestamp),
+				URL:        entry.ArchiveURL(),
+				Archive:    entry.Archive(),
+				WARCFile:   entry.Field(timetraveller.FieldWARCFile),
+				WARCOffset: entry.Field(timetraveller.FieldWARCOffset),
+			})
+		}
+	}
+	if changes {
+		for _, entry := range r.Changes() {
+			out.Changes = append(out.Changes, jsonSnapshot{
+				Timestamp:  entry.Field(timetraveller.FieldTimestamp),
+				URL:        entry.ArchiveURL(),
+				Digest:     entry.Field(timetraveller.FieldDigest),
+				Archive:    entry.Archive(),
+				WARCFile:   entry.Field(timetraveller.FieldWARCFile),
+				WARCOffset: entry.Field(timetraveller.FieldWARCOffset),
+			})
+		}
+	}
+	return out
+}
+
+// jsonArrayWriter writes a JSON array one element at a time, formatted like an
+// indented json.Encoder would, so -json output is not held in memory.
+type jsonArrayWriter struct {
+	w io.Writer
+	n int
+}
+
+func (a *jsonArrayWriter) write(v any) error {
+	data, err := json.MarshalIndent(v, "  ", "  ")
+	if err != nil {
+		return err
+	}
+	sep := ",\n  "
+	if a.n == 0 {
+		sep = "[\n  "
+	}
+	a.n++
+	_, err = fmt.Fprintf(a.w, "%s%s", sep, data)
+	return err
+}
+
+// close ends the array.
+func (a *jsonArrayWriter) close() error {
+	end := "\n]\n"
+	if a.n == 0 {
+		end = "[]\n"
+	}
+	_, err := io.WriteString(a.w, end)
+	return err
+}
+
+// snapshotCSVHeader names the columns written by writeSnapshotCSV: the input
+// URL followed by the CDX fields in their API order.
+var snapshotCSVHeader = []string{"url", "urlkey", "timestamp", "original", "mimetype", "statuscode", "digest", "length"}
+
+// verifyCSVHeader names the columns a -verify-map run adds to snapshotCSVHeader.
+var verifyCSVHeader = []string{"expected_archive_url", "match"}
+
+// writeSnapshotCSV writes one row per snapshot of the result, ending with the
+// extra columns. With extra columns, a result without snapshots still gets a
+// row of its own.
+func writeSnapshotCSV(w *csv.Writer, r timetraveller.ProcessResult, extra ...string) error {
+	entries := r.Snapshots
+	if len(entries) == 0 && len(extra) > 0 {
+		entries = []timetraveller.SnapshotEntry{nil}
+	}
+	for _, entry := range entries {
+		row := make([]string, len(snapshotCSVHeader), len(snapshotCSVHeader)+len(extra))
+		row[0] = r.URL
+		for i := range row[1:] {
+			if i < len(entry) && entry[i] != nil {
+				row[i+1] = fmt.Sprint(entry[i])
+			}
+		}
+		if err := w.Write(append(row, extra...)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// snapshotURLs returns the archive URLs a found result contributes to the -o
+// file: the chosen snapshot, every snapshot when all is set, or the content
+// changes when changes is set.
+func snapshotURLs(r timetraveller.ProcessResult, all, changes bool) []string {
+	var entries []timetraveller.SnapshotEntry
+	switch {
+	case all:
+		entries = r.Snapshots
+	case changes:
+		entries = r.Changes()
+	case r.OldestURL == "":
+		return nil
+	default:
+		return []string{r.OldestURL}
+	}
+	urls := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		urls = append(urls, entry.ArchiveURL())
+	}
+	return urls
+}
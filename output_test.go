@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/csv"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/aleister1102/timetraveller/pkg/timetraveller"
+)
+
+func TestWriteSnapshotCSV(t *testing.T) {
+	tests := []struct {
+		name      string
+		snapshots []timetraveller.SnapshotEntry
+		want      string
+	}{
+		{"no snapshots", nil, ""},
+		{
+			"one row per snapshot",
+			[]timetraveller.SnapshotEntry{
+				{"com,example)/", "20010101000000", "https://example.com/", "text/html", "200", "AAA", "100"},
+				{"com,example)/", "20150101000000", "https://example.com/", "text/html", "200", "BBB", "120"},
+			},
+			"https://example.com/,\"com,example)/\",20010101000000,https://example.com/,text/html,200,AAA,100\n" +
+				"https://example.com/,\"com,example)/\",20150101000000,https://example.com/,text/html,200,BBB,120\n",
+		},
+		{
+			"short rows padded",
+			[]timetraveller.SnapshotEntry{{"com,example)/", "20010101000000", "https://example.com/"}},
+			"https://example.com/,\"com,example)/\",20010101000000,https://example.com/,,,,\n",
+		},
+		{
+			"memento column dropped",
+			[]timetraveller.SnapshotEntry{{"com,example)/", "20010101000000", "https://example.com/", "text/html", "200", "AAA", "100",
+				"https://arquivo.pt/wayback/20010101000000/https://example.com/"}},
+			"https://example.com/,\"com,example)/\",20010101000000,https://example.com/,text/html,200,AAA,100\n",
+		},
+		{
+			"fields quoted",
+			[]timetraveller.SnapshotEntry{{"com,example)/", "20010101000000", "https://example.com/?q=\"a\"", "text/html; charset=utf-8", "200", "AAA", "100"}},
+			"https://example.com/,\"com,example)/\",20010101000000,\"https://example.com/?q=\"\"a\"\"\",text/html; charset=utf-8,200,AAA,100\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			w := csv.NewWriter(&out)
+			if err := writeSnapshotCSV(w, timetraveller.ProcessResult{URL: "https://example.com/", Snapshots: tt.snapshots}); err != nil {
+				t.Fatal(err)
+			}
+			w.Flush()
+			if got := out.String(); got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+// foundResult returns a found result of https://example.com/ with captures
+// at the given timestamps and digests, the first one chosen.
+func foundResult(captures ...[2]string) timetraveller.ProcessResult {
+	r := timetraveller.ProcessResult{URL: "https://example.com/", Status: timetraveller.StatusFound, SnapshotCount: len(captures)}
+	for _, c := range captures {
+		r.Snapshots = append(r.Snapshots, timetraveller.SnapshotEntry{"com,example)/", c[0], "https://example.com/", "text/html", "200", c[1], "100"})
+	}
+	r.Chosen = r.Snapshots[0]
+	r.OldestURL = r.Chosen.ArchiveURL()
+	return r
+}
+
+func TestSnapshotURLs(t *testing.T) {
+	three := foundResult([2]string{"20010101000000", "A"}, [2]string{"20050101000000", "A"}, [2]string{"20100101000000", "B"})
+	tests := []struct {
+		name         string
+		result       timetraveller.ProcessResult
+		all, changes bool
+		want         []string
+	}{
+		{"chosen snapshot", three, false, false, []string{"http://web.archive.org/web/20010101000000/https://example.com/"}},
+		{"every snapshot with -all", three, true, false, []string{
+			"http://web.archive.org/web/20010101000000/https://example.com/",
+			"http://web.archive.org/web/20050101000000/https://example.com/",
+			"http://web.archive.org/web/20100101000000/https://example.com/",
+		}},
+		{"content changes with -changes", three, false, true, []string{
+			"http://web.archive.org/web/20010101000000/https://example.com/",
+			"http://web.archive.org/web/20100101000000/https://example.com/",
+		}},
+		{"nothing for a missing URL", timetraveller.ProcessResult{URL: "https://example.com/", Status: timetraveller.StatusNotFound}, true, false, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := snapshotURLs(tt.result, tt.all, tt.changes); !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewJSONResultAll(t *testing.T) {
+	two := foundResult([2]string{"20010101000000", "A"}, [2]string{"20100101000000", "B"})
+	tests := []struct {
+		name string
+		all  bool
+		want []jsonSnapshot
+	}{
+		{"snapshots listed with -all", true, []jsonSnapshot{
+			{Timestamp: "20010101000000", URL: "http://web.archive.org/web/20010101000000/https://example.com/", Archive: "web.archive.org"},
+			{Timestamp: "20100101000000", URL: "http://web.archive.org/web/20100101000000/https://example.com/", Archive: "web.archive.org"},
+		}},
+		{"snapshots omitted without -all", false, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := newJSONResult(two, tt.all, false)
+			if !slices.Equal(got.Snapshots, tt.want) {
+				t.Errorf("got %+v, want %+v", got.Snapshots, tt.want)
+			}
+			if got.SnapshotCount != 2 || got.SnapshotURL != two.OldestURL {
+				t.Errorf("got count %d and URL %s, want 2 and %s", got.SnapshotCount, got.SnapshotURL, two.OldestURL)
+			}
+		})
+	}
+}
+
+func TestNewJSONResultWARCRecord(t *testing.T) {
+	const record = "https://index.commoncrawl.org/CC-MAIN-2024-10-index?from=20240101000000&output=json&to=20240101000000&url=https%3A%2F%2Fexample.com%2F"
+	const warc = "https://data.commoncrawl.org/crawl-data/CC-MAIN-2024-10/example.warc.gz"
+	entry := timetraveller.SnapshotEntry{"com,example)/", "20240101000000", "https://example.com/", "text/html", "200", "D", "1234", record, warc, "5678"}
+	r := timetraveller.ProcessResult{URL: "https://example.com/", Status: timetraveller.StatusFound, SnapshotCount: 1,
+		Chosen: entry, OldestURL: entry.ArchiveURL(), Snapshots: []timetraveller.SnapshotEntry{entry}}
+
+	got := newJSONResult(r, true, false)
+	if got.SnapshotURL != record || got.WARCFile != warc || got.WARCOffset != "5678" {
+		t.Errorf("got snapshot URL %s, WARC file %s at %s; want %s, %s at 5678", got.SnapshotURL, got.WARCFile, got.WARCOffset, record, warc)
+	}
+	want := jsonSnapshot{Timestamp: "20240101000000", URL: record, Archive: "index.commoncrawl.org", WARCFile: warc, WARCOffset: "5678"}
+	if len(got.Snapshots) != 1 || got.Snapshots[0] != want {
+		t.Errorf("got snapshots %+v, want %+v", got.Snapshots, want)
+	}
+}
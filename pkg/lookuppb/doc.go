@@ -0,0 +1,5 @@
+// Package lookuppb holds the gRPC service of "timetraveller serve
+// -grpc-listen", generated from lookup.proto.
+package lookuppb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative lookup.proto
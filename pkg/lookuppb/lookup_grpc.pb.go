@@ -0,0 +1,125 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: lookup.proto
+
+package lookuppb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Lookup_Lookup_FullMethodName = "/timetraveller.v1.Lookup/Lookup"
+)
+
+// LookupClient is the client API for Lookup service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Lookup finds archived snapshots of URLs.
+type LookupClient interface {
+	// Lookup looks up every URL streamed in and streams back one result per URL
+	// as soon as it is ready, so results arrive in completion order. The
+	// server's flags set the lookup options.
+	Lookup(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[LookupRequest, LookupResult], error)
+}
+
+type lookupClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewLookupClient(cc grpc.ClientConnInterface) LookupClient {
+	return &lookupClient{cc}
+}
+
+func (c *lookupClient) Lookup(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[LookupRequest, LookupResult], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Lookup_ServiceDesc.Streams[0], Lookup_Lookup_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[LookupRequest, LookupResult]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Lookup_LookupClient = grpc.BidiStreamingClient[LookupRequest, LookupResult]
+
+// LookupServer is the server API for Lookup service.
+// All implementations must embed UnimplementedLookupServer
+// for forward compatibility.
+//
+// Lookup finds archived snapshots of URLs.
+type LookupServer interface {
+	// Lookup looks up every URL streamed in and streams back one result per URL
+	// as soon as it is ready, so results arrive in completion order. The
+	// server's flags set the lookup options.
+	Lookup(grpc.BidiStreamingServer[LookupRequest, LookupResult]) error
+	mustEmbedUnimplementedLookupServer()
+}
+
+// UnimplementedLookupServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedLookupServer struct{}
+
+func (UnimplementedLookupServer) Lookup(grpc.BidiStreamingServer[LookupRequest, LookupResult]) error {
+	return status.Errorf(codes.Unimplemented, "method Lookup not implemented")
+}
+func (UnimplementedLookupServer) mustEmbedUnimplementedLookupServer() {}
+func (UnimplementedLookupServer) testEmbeddedByValue()                {}
+
+// UnsafeLookupServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to LookupServer will
+// result in compilation errors.
+type UnsafeLookupServer interface {
+	mustEmbedUnimplementedLookupServer()
+}
+
+func RegisterLookupServer(s grpc.ServiceRegistrar, srv LookupServer) {
+	// If the following call pancis, it indicates UnimplementedLookupServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Lookup_ServiceDesc, srv)
+}
+
+func _Lookup_Lookup_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(LookupServer).Lookup(&grpc.GenericServerStream[LookupRequest, LookupResult]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Lookup_LookupServer = grpc.BidiStreamingServer[LookupRequest, LookupResult]
+
+// Lookup_ServiceDesc is the grpc.ServiceDesc for Lookup service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Lookup_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "timetraveller.v1.Lookup",
+	HandlerType: (*LookupServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Lookup",
+			Handler:       _Lookup_Lookup_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "lookup.proto",
+}
@@ -0,0 +1,705 @@
+package timetraveller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"math/rand/v2"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fetchURLData fetches snapshot data for a given URL from the CDX API.
+// It implements retry logic with exponential backoff for network errors and rate limiting.
+func (c *Client) fetchURLData(ctx context.Context, targetURL string, opts Options) ProcessResult {
+	if opts.Availability {
+		return c.fetchAvailability(ctx, targetURL, opts)
+	}
+
+	snapshots, err := c.fetchSnapshots(ctx, targetURL, opts)
+	if err != nil {
+		return ProcessResult{URL: targetURL, Status: StatusError, Error: err}
+	}
+	return newResult(targetURL, snapshots, opts)
+}
+
+// newResult builds the result of a lookup of targetURL that found snapshots,
+// selecting one according to opts.
+func newResult(targetURL string, snapshots []SnapshotEntry, opts Options) ProcessResult {
+	result := ProcessResult{URL: targetURL}
+
+	if opts.MatchType != "" && opts.MatchType != MatchExact {
+		// Wider matches come back ordered by URL key; order them by capture time
+		// so oldest/latest selection spans every matched URL.
+		sort.SliceStable(snapshots, func(i, j int) bool {
+			return snapshots[i].Field(FieldTimestamp) < snapshots[j].Field(FieldTimestamp)
+		})
+	}
+
+	snapshotCount := len(snapshots)
+
+	if opts.CountOnly || len(opts.Fields) > 0 {
+		result.Status = StatusNotFound
+		if snapshotCount > 0 {
+			result.Status = StatusFound
+			result.SnapshotCount = snapshotCount
+			if !opts.CountOnly {
+				result.Snapshots = snapshots
+			}
+		}
+		return result
+	}
+
+	if snapshotCount > 0 {
+		result.Status = StatusFound
+		result.SnapshotCount = snapshotCount
+		result.Snapshots = snapshots
+
+		chosenEntry := SelectSnapshot(snapshots, opts)
+		result.Chosen = chosenEntry
+
+		if len(chosenEntry) > 2 {
+			timestamp, tsOk := chosenEntry[1].(string)
+			originalURL, origOk := chosenEntry[2].(string)
+
+			if tsOk && origOk {
+				result.OldestURL = chosenEntry.ArchiveURL()
+				if opts.DetailsLink && chosenEntry.Field(FieldMementoURL) == "" {
+					result.DetailsURL = fmt.Sprintf("http://web.archive.org/web/%s*/%s", timestamp, originalURL)
+				}
+			} else {
+				result.OldestURL = "could not determine (error parsing snapshot data)"
+			}
+		} else {
+			result.OldestURL = "could not determine (not enough fields in snapshot data)"
+		}
+	} else {
+		result.Status = StatusNotFound
+	}
+	return result
+}
+
+// fetchSnapshots returns every capture of targetURL, from the client's cache
+// if it holds a fresh entry for the lookup.
+func (c *Client) fetchSnapshots(ctx context.Context, targetURL string, opts Options) ([]SnapshotEntry, error) {
+	if c.Cache == nil {
+		return c.querySnapshots(ctx, targetURL, opts)
+	}
+	key := cacheKey(targetURL, opts)
+	if snapshots, ok := c.Cache.get(key); ok {
+		return snapshots, nil
+	}
+	snapshots, err := c.querySnapshots(ctx, targetURL, opts)
+	if err == nil {
+		// A failed write only costs a query on the next run.
+		_ = c.Cache.put(key, snapshots)
+	}
+	return snapshots, err
+}
+
+// querySnapshots returns every capture of targetURL from the providers named
+// in opts, or from opts.TimeMap. Captures of several providers are merged in
+// capture-time order; a failure of any provider fails the lookup.
+func (c *Client) querySnapshots(ctx context.Context, targetURL string, opts Options) ([]SnapshotEntry, error) {
+	if opts.TimeMap != "" {
+		start := time.Now()
+		snapshots, err := (&timeMapProvider{c, opts.TimeMap}).Query(ctx, targetURL, opts)
+		c.queried(ProviderMemento, start, err)
+		return snapshots, err
+	}
+
+	names := strings.Split(opts.Provider, ",")
+	var snapshots []SnapshotEntry
+	for _, name := range names {
+		provider, err := c.provider(name)
+		if err != nil {
+			return nil, err
+		}
+		start := time.Now()
+		found, err := provider.Query(ctx, targetURL, c.providerOptions(name, opts))
+		c.queried(name, start, err)
+		if err != nil {
+			if len(names) > 1 {
+				err = fmt.Errorf("%s: %w", name, err)
+			}
+			return nil, err
+		}
+		snapshots = append(snapshots, found...)
+	}
+	if len(names) > 1 {
+		sort.SliceStable(snapshots, func(i, j int) bool {
+			return snapshots[i].Field(FieldTimestamp) < snapshots[j].Field(FieldTimestamp)
+		})
+	}
+	return snapshots, nil
+}
+
+// queried reports a provider query that started at start to OnQuery.
+func (c *Client) queried(provider string, start time.Time, err error) {
+	if c.OnQuery == nil {
+		return
+	}
+	if provider == "" {
+		provider = ProviderWayback
+	}
+	c.OnQuery(provider, time.Since(start), err)
+}
+
+// waybackProvider queries the Wayback Machine's CDX API.
+type waybackProvider struct {
+	c *Client
+}
+
+// Query returns every capture of targetURL, following CDX resume keys so that
+// results the API splits across pages are aggregated.
+func (p *waybackProvider) Query(ctx context.Context, targetURL string, opts Options) ([]SnapshotEntry, error) {
+	endpoint := cdxAPIURL
+	if opts.CDXURL != "" {
+		endpoint = opts.CDXURL
+	}
+	apiURL, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing base API URL: %w", err)
+	}
+
+	var snapshots []SnapshotEntry
+	resumeKey := ""
+	for {
+		query := cdxQuery(targetURL, opts)
+		query.Set("showResumeKey", "true")
+		if limit := cdxPageSize(opts); limit > 0 {
+			query.Set("limit", strconv.Itoa(limit))
+		}
+		if resumeKey != "" {
+			query.Set("resumeKey", resumeKey)
+		}
+		apiURL.RawQuery = query.Encode()
+
+		body, err := p.c.get(ctx, apiURL.String(), opts)
+		if err != nil {
+			if opts.CDXURL != "" {
+				// pywb answers 404 when it holds no captures.
+				err = ignoreNotFound(err)
+			}
+			return snapshots, err
+		}
+		var page []SnapshotEntry
+		var nextKey string
+		if bytes.HasPrefix(bytes.TrimSpace(body), []byte("{")) {
+			// pywb answers output=json with one object per line and no resume keys.
+			page = parsePywbRecords(body, pywbFields(opts), nil)
+		} else if page, nextKey, err = parseCDXPage(body); err != nil {
+			return nil, err
+		}
+		if opts.PlaybackURL != "" {
+			for i, entry := range page {
+				// Full rows get the archive's own snapshot URL.
+				if len(entry) == FieldMementoURL {
+					page[i] = append(entry, opts.PlaybackURL+entry.Field(FieldTimestamp)+"/"+entry.Field(FieldOriginal))
+				}
+			}
+		}
+		snapshots = append(snapshots, page...)
+
+		if nextKey == "" || nextKey == resumeKey {
+			return snapshots, nil
+		}
+		resumeKey = nextKey
+	}
+}
+
+// defaultPageSize is the number of rows requested per page of the Wayback
+// Machine's CDX API when Options.PageSize is unset. The API only ends a page
+// with a resume key when a limit is sent, so without one it would silently
+// truncate large results.
+const defaultPageSize = 10000
+
+// cdxPageSize returns the limit to send with a CDX query, or 0 for none.
+// Self-hosted endpoints only get an explicit PageSize: pywb has no resume
+// keys, so a default limit would truncate its results instead.
+func cdxPageSize(opts Options) int {
+	if opts.PageSize > 0 {
+		return opts.PageSize
+	}
+	if opts.CDXURL == "" {
+		return defaultPageSize
+	}
+	return 0
+}
+
+// providerOptions returns opts adjusted to the ProviderSettings of the named
+// provider, if the client has any.
+func (c *Client) providerOptions(name string, opts Options) Options {
+	if name == "" {
+		name = ProviderWayback
+	}
+	settings, ok := c.ProviderSettings[name]
+	if !ok {
+		return opts
+	}
+	opts.RetryAttempts = settings.RetryAttempts
+	opts.RetryDelayMs = settings.RetryDelayMs
+	opts.settings = &settings
+	return opts
+}
+
+// parseCDXPage decodes one page of CDX JSON output into snapshot rows, dropping
+// the header row. With showResumeKey, the API ends a truncated page with an
+// empty row followed by a row holding the key for the next page.
+func parseCDXPage(body []byte) ([]SnapshotEntry, string, error) {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil, "", nil
+	}
+
+	var cdxResponse [][]interface{}
+	if err := json.Unmarshal(body, &cdxResponse); err != nil {
+		return nil, "", fmt.Errorf("error decoding JSON response: %w", err)
+	}
+
+	resumeKey := ""
+	if n := len(cdxResponse); n >= 2 && len(cdxResponse[n-2]) == 0 && len(cdxResponse[n-1]) == 1 {
+		resumeKey, _ = cdxResponse[n-1][0].(string)
+		cdxResponse = cdxResponse[:n-2]
+	}
+
+	var snapshots []SnapshotEntry
+	if len(cdxResponse) > 1 {
+		for _, entryData := range cdxResponse[1:] {
+			snapshots = append(snapshots, SnapshotEntry(entryData))
+		}
+	}
+	return snapshots, resumeKey, nil
+}
+
+// Get performs a GET request with the client's retries and per-host limiter,
+// returning the body of a 200 response. It is meant for Provider
+// implementations.
+func (c *Client) Get(ctx context.Context, rawURL string, opts Options) ([]byte, error) {
+	return c.get(ctx, rawURL, opts)
+}
+
+// get performs a GET request against the archive and returns the response body.
+// Network errors, rate limiting, 5xx responses and configured body markers are
+// retried with exponential backoff.
+func (c *Client) get(ctx context.Context, rawURL string, opts Options) ([]byte, error) {
+	return c.getLimited(ctx, rawURL, opts, 0)
+}
+
+// getLimited is like get but fails once the body exceeds maxBytes (0 = unlimited).
+func (c *Client) getLimited(ctx context.Context, rawURL string, opts Options, maxBytes int64) ([]byte, error) {
+	retryAttempts := opts.RetryAttempts
+	var lastErr error
+	retryAfter := false // The last response set a Retry-After pause
+
+	for attempt := 0; attempt <= retryAttempts; attempt++ {
+		// Add exponential backoff delay before retrying, unless the server said
+		// how long to wait: acquire then holds every request to the host back.
+		if attempt > 0 {
+			c.stats.retries.Add(1)
+		}
+		if attempt > 0 && !retryAfter {
+			delay := backoffDelay(attempt, opts)
+			c.log(slog.LevelInfo, "retrying request", "url", rawURL, "attempt", attempt, "backoff", delay, "reason", lastErr)
+			select {
+			case <-ctx.Done():
+				return nil, fmt.Errorf("aborted while waiting to retry: %w", ctx.Err())
+			case <-time.After(delay):
+			}
+		}
+
+		reqCtx, cancel := ctx, context.CancelFunc(func() {})
+		if opts.settings != nil && opts.settings.Timeout > 0 {
+			reqCtx, cancel = context.WithTimeout(ctx, opts.settings.Timeout)
+		}
+		req, err := http.NewRequestWithContext(reqCtx, "GET", rawURL, nil)
+		if err != nil {
+			cancel()
+			return nil, fmt.Errorf("error creating request: %w", err)
+		}
+		if user, password, ok := strings.Cut(opts.ArchiveItAuth, ":"); ok && isArchiveItHost(req.URL.Hostname()) {
+			req.SetBasicAuth(user, password)
+		}
+
+		release, err := c.acquire(ctx, req.URL.Host, opts)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		c.stats.requests.Add(1)
+		start := time.Now()
+		resp, err := c.HTTPClient.Do(req)
+		if err != nil {
+			c.log(slog.LevelDebug, "request failed", "url", rawURL, "duration", time.Since(start), "error", err)
+			release()
+			cancel()
+			if ctx.Err() == nil {
+				c.Breaker.Failure()
+			}
+			lastErr = err // Network error
+			if attempt < retryAttempts && ctx.Err() == nil {
+				continue
+			}
+			return nil, fmt.Errorf("error fetching data after %d retries: %w", retryAttempts, lastErr)
+		}
+
+		// Read body to check for custom rate limit message.
+		var body io.Reader = resp.Body
+		if maxBytes > 0 {
+			body = io.LimitReader(resp.Body, maxBytes+1)
+		}
+		bodyBytes, readErr := io.ReadAll(body)
+		resp.Body.Close()
+		release()
+		cancel()
+		c.log(slog.LevelDebug, "request", "url", rawURL, "status", resp.StatusCode, "bytes", len(bodyBytes), "duration", time.Since(start))
+		if readErr != nil {
+			return nil, fmt.Errorf("error reading response body: %w", readErr)
+		}
+		if maxBytes > 0 && int64(len(bodyBytes)) > maxBytes {
+			return nil, fmt.Errorf("response body exceeds the %d byte limit", maxBytes)
+		}
+
+		// Check for retryable conditions: rate limiting or server-side errors (5xx).
+		is429 := resp.StatusCode == http.StatusTooManyRequests
+		is5xx := resp.StatusCode >= 500 && resp.StatusCode < 600
+		retryAfter = false
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+			if wait, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+				c.log(slog.LevelInfo, "pausing host for Retry-After", "host", req.URL.Host, "wait", wait)
+				c.pause(req.URL.Host, wait)
+				retryAfter = true
+			}
+		}
+		isRateLimitMessage := strings.Contains(string(bodyBytes), "You have sent too many requests in a given amount of time.")
+		bodyMarker := ""
+		if resp.StatusCode == http.StatusOK {
+			bodyMarker = findBodyMarker(bodyBytes, opts.RetryOnBody)
+		}
+
+		if is429 || isRateLimitMessage {
+			c.Adaptive.Throttled()
+			c.stats.rateLimited.Add(1)
+			n := c.rateLimited.Add(1)
+			c.log(slog.LevelInfo, "rate limited", "url", rawURL, "status", resp.StatusCode, "consecutive", n)
+			if c.OnRateLimited != nil {
+				c.OnRateLimited(int(n))
+			}
+		} else if resp.StatusCode == http.StatusOK {
+			c.Adaptive.Succeeded()
+			c.rateLimited.Store(0)
+		}
+		if is5xx {
+			c.Breaker.Failure()
+		} else {
+			c.Breaker.Success()
+		}
+
+		if is429 || is5xx || isRateLimitMessage || bodyMarker != "" {
+			if is429 || isRateLimitMessage {
+				lastErr = fmt.Errorf("%w. Status: %s", errRateLimited, resp.Status)
+			} else if bodyMarker != "" {
+				lastErr = fmt.Errorf("%w %q", errRetryMarker, bodyMarker)
+			} else { // is5xx
+				lastErr = fmt.Errorf("%w. Status: %s", errServer, resp.Status)
+			}
+
+			if attempt < retryAttempts {
+				continue
+			}
+			return nil, fmt.Errorf("%w after %d retries", lastErr, retryAttempts)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, &statusError{code: resp.StatusCode, status: resp.Status, body: string(bodyBytes)}
+		}
+		return bodyBytes, nil
+	}
+
+	// Unreachable: every iteration either returns or continues to a later attempt.
+	if lastErr == nil {
+		lastErr = fmt.Errorf("unknown error; no response received")
+	}
+	return nil, fmt.Errorf("failed to get a response after all retries: %w", lastErr)
+}
+
+// acquire waits for the client's limiters to allow a request to host. The
+// returned release function must be called once the request completes.
+// Requests of a provider with ProviderSettings also wait for its RateLimiter.
+func (c *Client) acquire(ctx context.Context, host string, opts Options) (func(), error) {
+	if err := c.waitPause(ctx, host); err != nil {
+		return nil, fmt.Errorf("aborted while waiting for the server's Retry-After: %w", err)
+	}
+	if err := c.Breaker.Wait(ctx); err != nil {
+		return nil, fmt.Errorf("aborted while the circuit breaker is open: %w", err)
+	}
+	releaseHost, err := c.Limiter.Acquire(ctx, host)
+	if err != nil {
+		return nil, fmt.Errorf("aborted while waiting for a request slot: %w", err)
+	}
+	releaseAdaptive, err := c.Adaptive.Acquire(ctx)
+	if err != nil {
+		releaseHost()
+		return nil, fmt.Errorf("aborted while waiting for a request slot: %w", err)
+	}
+	if err := c.RateLimiter.Wait(ctx); err != nil {
+		releaseAdaptive()
+		releaseHost()
+		return nil, fmt.Errorf("aborted while waiting for a request slot: %w", err)
+	}
+	if opts.settings != nil {
+		if err := opts.settings.RateLimiter.Wait(ctx); err != nil {
+			releaseAdaptive()
+			releaseHost()
+			return nil, fmt.Errorf("aborted while waiting for a request slot: %w", err)
+		}
+	}
+	return func() {
+		releaseAdaptive()
+		releaseHost()
+	}, nil
+}
+
+// backoffDelay returns the wait before retry number attempt (from 1): the
+// retry delay doubled per attempt and capped at opts.MaxBackoffMs, of which a
+// random half is dropped so workers failing together do not retry in lockstep.
+func backoffDelay(attempt int, opts Options) time.Duration {
+	delay := time.Duration(opts.RetryDelayMs) * time.Millisecond
+	for i := 1; i < attempt && delay < time.Duration(math.MaxInt64/2); i++ {
+		delay *= 2
+	}
+	if maxBackoff := time.Duration(opts.MaxBackoffMs) * time.Millisecond; maxBackoff > 0 && delay > maxBackoff {
+		delay = maxBackoff
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// pause holds back every request to host for d, as asked by a Retry-After
+// header. Overlapping pauses extend to the latest end.
+func (c *Client) pause(host string, d time.Duration) {
+	until := time.Now().Add(d)
+	c.pauseMu.Lock()
+	defer c.pauseMu.Unlock()
+	if c.pausedUntil == nil {
+		c.pausedUntil = make(map[string]time.Time)
+	}
+	if until.After(c.pausedUntil[host]) {
+		c.pausedUntil[host] = until
+	}
+}
+
+// waitPause blocks while requests to host are paused or until ctx is done.
+func (c *Client) waitPause(ctx context.Context, host string) error {
+	for {
+		c.pauseMu.Lock()
+		wait := time.Until(c.pausedUntil[host])
+		c.pauseMu.Unlock()
+		if wait <= 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// parseRetryAfter parses a Retry-After header, given in seconds or as an HTTP
+// date, into the time left to wait.
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0), true
+	}
+	return 0, false
+}
+
+// Errors of responses that are retried.
+var (
+	errRateLimited = errors.New("API request failed due to rate limiting")
+	errRetryMarker = errors.New("API response contained retry marker")
+	errServer      = errors.New("API request failed with server error")
+)
+
+// Kinds of lookup errors, as reported by ErrorKind.
+const (
+	ErrorRateLimited = "rate_limited" // Still rate-limited after every retry
+	ErrorServer      = "server_error" // 5xx responses or retry markers after every retry
+	ErrorStatus      = "http_status"  // Another unexpected HTTP status, such as 404
+	ErrorTimeout     = "timeout"
+	ErrorNetwork     = "network" // Connection failures, DNS errors and the like
+	ErrorCanceled    = "canceled"
+	ErrorOther       = "other"
+)
+
+// ErrorKind classifies the Error of a ProcessResult, for tallying failures.
+func ErrorKind(err error) string {
+	var status *statusError
+	var netErr net.Error
+	switch {
+	case errors.Is(err, errRateLimited):
+		return ErrorRateLimited
+	case errors.Is(err, errServer), errors.Is(err, errRetryMarker):
+		return ErrorServer
+	case errors.As(err, &status):
+		return ErrorStatus
+	case errors.Is(err, context.Canceled):
+		return ErrorCanceled
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return ErrorTimeout
+	case errors.As(err, &netErr):
+		return ErrorNetwork
+	}
+	return ErrorOther
+}
+
+// statusError reports a non-200 response that is not worth retrying.
+type statusError struct {
+	code   int
+	status string
+	body   string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("API request failed. Status: %s, Body: %s", e.status, e.body)
+}
+
+// cdxQuery builds the CDX API query parameters for a lookup.
+func cdxQuery(targetURL string, opts Options) url.Values {
+	query := url.Values{}
+	query.Set("url", targetURL)
+	query.Set("output", "json")
+	if opts.MatchType != "" {
+		query.Set("matchType", opts.MatchType)
+	}
+	if opts.CountOnly {
+		// Only timestamps are needed to count captures.
+		query.Set("fl", "timestamp")
+	} else if len(opts.Fields) > 0 {
+		query.Set("fl", strings.Join(opts.Fields, ","))
+	}
+	if len(opts.Filters) > 0 {
+		for _, filter := range opts.Filters {
+			query.Add("filter", filter)
+		}
+	} else if !opts.AnyStatus {
+		query.Set("filter", "statuscode:200")
+	}
+	if len(opts.Mimetypes) > 0 {
+		patterns := make([]string, len(opts.Mimetypes))
+		for i, mime := range opts.Mimetypes {
+			patterns[i] = regexp.QuoteMeta(mime)
+		}
+		query.Add("filter", "mimetype:"+strings.Join(patterns, "|"))
+	}
+	for _, collapse := range opts.Collapse {
+		query.Add("collapse", collapse)
+	}
+	if opts.From != "" {
+		query.Set("from", opts.From)
+	}
+	if opts.To != "" {
+		query.Set("to", opts.To)
+	}
+	return query
+}
+
+// SelectSnapshot picks the oldest, latest or closest snapshot according to opts,
+// preferring the earliest mimetype in opts.MimePreference that has any capture.
+// Snapshots must be non-empty and ordered by capture time.
+func SelectSnapshot(snapshots []SnapshotEntry, opts Options) SnapshotEntry {
+	var target time.Time
+	if opts.Closest != "" {
+		target, _ = ParseTimestamp(opts.Closest)
+	}
+
+	pick := func(match func(SnapshotEntry) bool) SnapshotEntry {
+		if opts.Closest != "" {
+			var best SnapshotEntry
+			var bestDistance time.Duration
+			for _, entry := range snapshots {
+				if !match(entry) {
+					continue
+				}
+				captured, err := ParseTimestamp(entry.Field(FieldTimestamp))
+				if err != nil {
+					continue
+				}
+				distance := captured.Sub(target)
+				if distance < 0 {
+					distance = -distance
+				}
+				if best == nil || distance < bestDistance {
+					best, bestDistance = entry, distance
+				}
+			}
+			return best
+		}
+		if opts.Latest {
+			for i := len(snapshots) - 1; i >= 0; i-- {
+				if match(snapshots[i]) {
+					return snapshots[i]
+				}
+			}
+			return nil
+		}
+		for _, entry := range snapshots {
+			if match(entry) {
+				return entry
+			}
+		}
+		return nil
+	}
+
+	for _, preferred := range opts.MimePreference {
+		preferred = mediaType(preferred)
+		if entry := pick(func(e SnapshotEntry) bool { return mediaType(e.Field(FieldMimetype)) == preferred }); entry != nil {
+			return entry
+		}
+	}
+	if entry := pick(func(SnapshotEntry) bool { return true }); entry != nil {
+		return entry
+	}
+	// Only reachable in closest mode when no timestamp parses.
+	return snapshots[0]
+}
+
+// mediaType returns the lowercased media type of a mimetype, without its
+// parameters, so "Text/HTML; charset=utf-8" matches "text/html".
+func mediaType(mimetype string) string {
+	if mediatype, _, err := mime.ParseMediaType(mimetype); err == nil {
+		return mediatype
+	}
+	mediatype, _, _ := strings.Cut(mimetype, ";")
+	return strings.ToLower(strings.TrimSpace(mediatype))
+}
+
+// findBodyMarker returns the first marker contained in body, or "" if none match.
+func findBodyMarker(body []byte, markers []string) string {
+	for _, marker := range markers {
+		if bytes.Contains(body, []byte(marker)) {
+			return marker
+		}
+	}
+	return ""
+}
@@ -0,0 +1,186 @@
+package timetraveller
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+// entry returns a CDX row of a capture at timestamp with mimetype.
+func entry(timestamp, mimetype string) SnapshotEntry {
+	return SnapshotEntry{"com,example)/", timestamp, "https://example.com/", mimetype, "200", "DIGEST", "100"}
+}
+
+func TestSelectSnapshot(t *testing.T) {
+	mixed := []SnapshotEntry{
+		entry("20010101000000", "text/html"),
+		entry("20050101000000", "application/pdf"),
+		entry("20100101000000", "application/json"),
+		entry("20150101000000", "text/html"),
+		entry("20200101000000", "application/pdf"),
+	}
+	tests := []struct {
+		name      string
+		snapshots []SnapshotEntry
+		opts      Options
+		want      string // Timestamp of the selected capture
+	}{
+		{"oldest", mixed, Options{}, "20010101000000"},
+		{"latest", mixed, Options{Latest: true}, "20200101000000"},
+		{"oldest of the preferred mimetype", mixed, Options{MimePreference: []string{"application/pdf"}}, "20050101000000"},
+		{"latest of the preferred mimetype", mixed, Options{Latest: true, MimePreference: []string{"application/json"}}, "20100101000000"},
+		{"first preference with a capture wins", mixed, Options{MimePreference: []string{"image/png", "application/json", "text/html"}}, "20100101000000"},
+		{"preference ignores case", mixed, Options{MimePreference: []string{"Application/PDF"}}, "20050101000000"},
+		{"preference ignores parameters", []SnapshotEntry{entry("20010101000000", "application/pdf"), entry("20050101000000", "text/html; charset=utf-8"), entry("20100101000000", "Text/HTML ")},
+			Options{MimePreference: []string{"text/html"}}, "20050101000000"},
+		{"preference with parameters", []SnapshotEntry{entry("20010101000000", "application/pdf"), entry("20100101000000", "Text/HTML ")},
+			Options{MimePreference: []string{"text/html; charset=utf-8"}}, "20100101000000"},
+		{"unparsable mimetype trimmed at its parameters", []SnapshotEntry{entry("20010101000000", "application/pdf"), entry("20050101000000", "Text/HTML; charset")},
+			Options{MimePreference: []string{"text/html"}}, "20050101000000"},
+		{"falls back to oldest without a preferred capture", mixed, Options{MimePreference: []string{"image/png"}}, "20010101000000"},
+		{"falls back to latest without a preferred capture", mixed, Options{Latest: true, MimePreference: []string{"image/png"}}, "20200101000000"},
+		{"closest to a year", mixed, Options{Closest: "2011"}, "20100101000000"},
+		{"closest after the target", mixed, Options{Closest: "201306"}, "20150101000000"},
+		{"closest to a full timestamp", mixed, Options{Closest: "20100101000001"}, "20100101000000"},
+		{"closest before every capture", mixed, Options{Closest: "1990"}, "20010101000000"},
+		{"closest after every capture", mixed, Options{Closest: "2030"}, "20200101000000"},
+		{"closest takes precedence over latest", mixed, Options{Closest: "2006", Latest: true}, "20050101000000"},
+		{"closest of the preferred mimetype", mixed, Options{Closest: "2012", MimePreference: []string{"application/pdf"}}, "20050101000000"},
+		{"closest skips unparsable timestamps", []SnapshotEntry{entry("garbage", "text/html"), entry("20200101000000", "text/html")}, Options{Closest: "2001"}, "20200101000000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SelectSnapshot(tt.snapshots, tt.opts).Field(FieldTimestamp); got != tt.want {
+				t.Errorf("selected %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryOnBody(t *testing.T) {
+	const maintenance = "<html>The archive is down for maintenance</html>"
+	cdx := `[["urlkey","timestamp","original","mimetype","statuscode","digest","length"],` +
+		`["com,example)/","20010101000000","https://example.com/","text/html","200","DIGEST","100"]]`
+	tests := []struct {
+		name         string
+		markers      []string
+		maintenances int // Maintenance pages served before the CDX output
+		wantStatus   string
+		wantRetries  int64
+	}{
+		{"maintenance page retried", []string{"down for maintenance"}, 1, StatusFound, 1},
+		{"every marker checked", []string{"rate exceeded", "down for maintenance"}, 2, StatusFound, 2},
+		{"maintenance page kept beyond the retries", []string{"down for maintenance"}, 5, StatusError, 2},
+		{"maintenance page not retried without a marker", nil, 1, StatusError, 0},
+		{"body without the marker not retried", []string{"down for maintenance"}, 0, StatusFound, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var served atomic.Int64
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if served.Add(1) <= int64(tt.maintenances) {
+					io.WriteString(w, maintenance)
+					return
+				}
+				io.WriteString(w, cdx)
+			}))
+			defer server.Close()
+
+			client := NewClient(server.Client())
+			result := client.Lookup(context.Background(), "https://example.com/", Options{
+				CDXURL: server.URL, RetryAttempts: 2, RetryDelayMs: 1, RetryOnBody: tt.markers,
+			})
+			if result.Status != tt.wantStatus {
+				t.Errorf("status %s (%v), want %s", result.Status, result.Error, tt.wantStatus)
+			}
+			if got := client.Stats().Retries; got != tt.wantRetries {
+				t.Errorf("%d retries, want %d", got, tt.wantRetries)
+			}
+		})
+	}
+}
+
+func TestCDXPageSize(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+		want int
+	}{
+		{"Wayback Machine default", Options{}, defaultPageSize},
+		{"Wayback Machine explicit", Options{PageSize: 500}, 500},
+		{"self-hosted default", Options{CDXURL: "http://localhost:8080/cdx"}, 0},
+		{"self-hosted explicit", Options{CDXURL: "http://localhost:8080/cdx", PageSize: 500}, 500},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cdxPageSize(tt.opts); got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWaybackQueryFollowsResumeKeys(t *testing.T) {
+	timestamps := []string{"20010101000000", "20020101000000", "20030101000000", "20040101000000", "20050101000000"}
+	tests := []struct {
+		name      string
+		pageSize  int
+		wantPages int
+	}{
+		{"pages of two", 2, 3},
+		{"one page with room to spare", 10, 1},
+		{"no limit", 0, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var pages int
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				pages++
+				query := r.URL.Query()
+				want := ""
+				if tt.pageSize > 0 {
+					want = strconv.Itoa(tt.pageSize)
+				}
+				if got := query.Get("limit"); got != want {
+					t.Errorf("limit %q, want %q", got, want)
+				}
+				start, _ := strconv.Atoi(query.Get("resumeKey"))
+				end := len(timestamps)
+				if tt.pageSize > 0 {
+					end = min(start+tt.pageSize, end)
+				}
+				rows := [][]string{{"urlkey", "timestamp", "original", "mimetype", "statuscode", "digest", "length"}}
+				for _, ts := range timestamps[start:end] {
+					rows = append(rows, []string{"com,example)/", ts, "https://example.com/", "text/html", "200", "D", "1"})
+				}
+				if end < len(timestamps) {
+					rows = append(rows, []string{}, []string{strconv.Itoa(end)})
+				}
+				json.NewEncoder(w).Encode(rows)
+			}))
+			defer server.Close()
+
+			p := &waybackProvider{c: NewClient(server.Client())}
+			snapshots, err := p.Query(context.Background(), "https://example.com/", Options{CDXURL: server.URL, PageSize: tt.pageSize})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, entry := range snapshots {
+				got = append(got, entry.Field(FieldTimestamp))
+			}
+			if !slices.Equal(got, timestamps) {
+				t.Errorf("got captures %q, want %q", got, timestamps)
+			}
+			if pages != tt.wantPages {
+				t.Errorf("%d pages requested, want %d", pages, tt.wantPages)
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package timetraveller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var errNoArchiveItCollection = errors.New("the archive-it provider needs a collection ID")
+
+// archiveItProvider queries an Archive-It collection through the collection's
+// CDX server, building replay URLs against wayback.archive-it.org.
+type archiveItProvider struct {
+	c *Client
+}
+
+// Query returns the captures of targetURL in opts.ArchiveItCollection.
+func (p *archiveItProvider) Query(ctx context.Context, targetURL string, opts Options) ([]SnapshotEntry, error) {
+	if opts.ArchiveItCollection == "" {
+		return nil, errNoArchiveItCollection
+	}
+	opts.CDXURL = fmt.Sprintf("%s%s/timemap/cdx", archiveItURL, opts.ArchiveItCollection)
+	opts.PlaybackURL = fmt.Sprintf("%s%s/", archiveItURL, opts.ArchiveItCollection)
+	return (&waybackProvider{p.c}).Query(ctx, targetURL, opts)
+}
+
+// isArchiveItHost reports whether host belongs to Archive-It, the only hosts
+// opts.ArchiveItAuth is sent to.
+func isArchiveItHost(host string) bool {
+	return host == "archive-it.org" || strings.HasSuffix(host, ".archive-it.org")
+}
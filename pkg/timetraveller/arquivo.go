@@ -0,0 +1,20 @@
+package timetraveller
+
+import "context"
+
+// arquivoProvider queries arquivo.pt, the Portuguese web archive, through its
+// pywb CDX server.
+type arquivoProvider struct {
+	c *Client
+}
+
+// Query returns the captures of targetURL in arquivo.pt.
+func (p *arquivoProvider) Query(ctx context.Context, targetURL string, opts Options) ([]SnapshotEntry, error) {
+	playback := func(record map[string]string) []string {
+		if record["timestamp"] == "" || record["url"] == "" {
+			return nil
+		}
+		return []string{arquivoPlaybackURL + record["timestamp"] + "/" + record["url"]}
+	}
+	return p.c.fetchPywbIndex(ctx, arquivoCDXURL, pywbQuery(targetURL, opts), pywbFields(opts), opts, playback)
+}
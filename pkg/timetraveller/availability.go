@@ -0,0 +1,71 @@
+package timetraveller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// availabilityResponse is the JSON returned by the availability API.
+type availabilityResponse struct {
+	ArchivedSnapshots struct {
+		Closest *struct {
+			Available bool   `json:"available"`
+			URL       string `json:"url"`
+			Timestamp string `json:"timestamp"`
+			Status    string `json:"status"`
+		} `json:"closest"`
+	} `json:"archived_snapshots"`
+}
+
+// fetchAvailability looks up a single snapshot of targetURL through the
+// availability API, which is far cheaper than a CDX query but only reports the
+// capture closest to a timestamp: the oldest, latest or closest capture is
+// found by asking for one near 1996, no timestamp, or opts.Closest.
+func (c *Client) fetchAvailability(ctx context.Context, targetURL string, opts Options) ProcessResult {
+	result := ProcessResult{URL: targetURL}
+
+	query := url.Values{}
+	query.Set("url", targetURL)
+	switch {
+	case opts.Closest != "":
+		query.Set("timestamp", opts.Closest)
+	case !opts.Latest:
+		query.Set("timestamp", "1996")
+	}
+
+	body, err := c.get(ctx, availabilityAPIURL+"?"+query.Encode(), opts)
+	if err != nil {
+		result.Status = StatusError
+		result.Error = err
+		return result
+	}
+	var response availabilityResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		result.Status = StatusError
+		result.Error = fmt.Errorf("error decoding JSON response: %w", err)
+		return result
+	}
+
+	closest := response.ArchivedSnapshots.Closest
+	if closest == nil || !closest.Available || closest.Timestamp == "" {
+		result.Status = StatusNotFound
+		return result
+	}
+	// The playback URL embeds the original URL after the timestamp.
+	original := targetURL
+	if _, rest, ok := strings.Cut(closest.URL, "/web/"+closest.Timestamp+"/"); ok {
+		original = rest
+	}
+	entry := SnapshotEntry{"", closest.Timestamp, original, "", closest.Status, "", ""}
+
+	result.Status = StatusFound
+	result.Chosen = entry
+	result.OldestURL = entry.ArchiveURL()
+	if opts.DetailsLink {
+		result.DetailsURL = fmt.Sprintf("http://web.archive.org/web/%s*/%s", closest.Timestamp, original)
+	}
+	return result
+}
@@ -0,0 +1,147 @@
+package timetraveller
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// batchable reports whether a lookup of targetURL with opts can be answered
+// from a host-wide query. Wildcards and wider match types already query more
+// than one URL, custom fields, collapsing and the availability API do not
+// return the rows needed to match captures to URLs, and TimeMaps, including
+// those of the memento and archive.today providers, only list exact URLs.
+func batchable(targetURL string, opts Options) bool {
+	return (opts.MatchType == "" || opts.MatchType == MatchExact) &&
+		!opts.Availability && opts.TimeMap == "" && !usesTimeMap(opts.Provider) &&
+		len(opts.Fields) == 0 && len(opts.Collapse) == 0 &&
+		!strings.Contains(targetURL, "*")
+}
+
+// usesTimeMap reports whether any of the comma-separated providers reads
+// captures from a TimeMap.
+func usesTimeMap(providers string) bool {
+	for _, name := range strings.Split(providers, ",") {
+		if name == ProviderMemento || name == ProviderArchiveToday {
+			return true
+		}
+	}
+	return false
+}
+
+// batchHost returns the host, with any non-default port, that the archive
+// indexes targetURL under.
+func batchHost(targetURL string) string {
+	canonical := Canonicalize(targetURL)
+	if end := strings.IndexAny(canonical, "/?"); end >= 0 {
+		return canonical[:end]
+	}
+	return canonical
+}
+
+// GroupByHost splits urls into batches for LookupBatches: the URLs of every
+// host with at least minURLs of them form one batch, and every other URL,
+// including those opts cannot batch, is a batch of its own. Batches keep the
+// order in which their first URL appears.
+func GroupByHost(urls []string, minURLs int, opts Options) [][]string {
+	byHost := make(map[string]int) // Index of the host's batch
+	var batches [][]string
+	for _, u := range urls {
+		if !batchable(u, opts) {
+			batches = append(batches, []string{u})
+			continue
+		}
+		host := batchHost(u)
+		if i, ok := byHost[host]; ok {
+			batches[i] = append(batches[i], u)
+			continue
+		}
+		byHost[host] = len(batches)
+		batches = append(batches, []string{u})
+	}
+
+	grouped := batches[:0:0]
+	for _, batch := range batches {
+		if len(batch) >= minURLs {
+			grouped = append(grouped, batch)
+			continue
+		}
+		for _, u := range batch {
+			grouped = append(grouped, []string{u})
+		}
+	}
+	return grouped
+}
+
+// LookupHost looks up each of targets, which must share a host, with a
+// single query for every capture on the host, matching the captures to the
+// targets locally by their canonical form. A failed query fails every
+// lookup.
+func (c *Client) LookupHost(ctx context.Context, targets []string, opts Options) []ProcessResult {
+	results := make([]ProcessResult, len(targets))
+	if len(targets) == 0 {
+		return results
+	}
+
+	hostOpts := opts
+	hostOpts.MatchType = MatchHost
+	// Counting needs the original URL of each capture to match it.
+	hostOpts.CountOnly = false
+	snapshots, err := c.fetchSnapshots(ctx, batchHost(targets[0]), hostOpts)
+	if err != nil {
+		for i, target := range targets {
+			results[i] = ProcessResult{URL: target, Status: StatusError, Error: err}
+		}
+		return results
+	}
+
+	byKey := make(map[string][]SnapshotEntry)
+	for _, entry := range snapshots {
+		key := SURT(entry.Field(FieldOriginal))
+		byKey[key] = append(byKey[key], entry)
+	}
+	for _, matched := range byKey {
+		// Host-wide results come back ordered by URL key, which several
+		// original URLs can share.
+		sort.SliceStable(matched, func(i, j int) bool {
+			return matched[i].Field(FieldTimestamp) < matched[j].Field(FieldTimestamp)
+		})
+	}
+	for i, target := range targets {
+		results[i] = newResult(target, byKey[SURT(target)], opts)
+	}
+	return results
+}
+
+// LookupBatches is like LookupAll, but receives batches of URLs from
+// GroupByHost. Batches of several URLs are looked up together with
+// LookupHost, and single URLs on their own.
+func (c *Client) LookupBatches(ctx context.Context, batches <-chan []string, workers int, delay time.Duration, opts Options) <-chan ProcessResult {
+	results := make(chan ProcessResult, workers)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range batches {
+				if len(batch) == 1 {
+					results <- c.fetchURLData(ctx, batch[0], opts)
+				} else {
+					for _, result := range c.LookupHost(ctx, batch, opts) {
+						results <- result
+					}
+				}
+				if delay > 0 {
+					time.Sleep(delay)
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	return results
+}
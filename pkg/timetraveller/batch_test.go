@@ -0,0 +1,106 @@
+package timetraveller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestGroupByHost(t *testing.T) {
+	urls := []string{
+		"https://a.example/1",
+		"https://b.example/1",
+		"https://WWW.a.example/2",
+		"https://a.example:8443/3",
+		"https://a.example/*",
+		"http://a.example/4",
+	}
+	batched := [][]string{
+		{"https://a.example/1", "https://WWW.a.example/2", "http://a.example/4"},
+		{"https://b.example/1"},
+		{"https://a.example:8443/3"},
+		{"https://a.example/*"},
+	}
+	unbatched := [][]string{{urls[0]}, {urls[1]}, {urls[2]}, {urls[3]}, {urls[4]}, {urls[5]}}
+	// Split batches keep their hosts' place.
+	split := [][]string{{urls[0]}, {urls[2]}, {urls[5]}, {urls[1]}, {urls[3]}, {urls[4]}}
+	tests := []struct {
+		name    string
+		minURLs int
+		opts    Options
+		want    [][]string
+	}{
+		{"exact URLs grouped by host", 2, Options{}, batched},
+		{"hosts below the minimum kept apart", 4, Options{}, split},
+		{"explicit exact match", 2, Options{MatchType: MatchExact}, batched},
+		{"CDX providers batched", 2, Options{Provider: "wayback,commoncrawl"}, batched},
+		{"wider match type", 2, Options{MatchType: MatchPrefix}, unbatched},
+		{"custom fields", 2, Options{Fields: []string{"original"}}, unbatched},
+		{"collapsing", 2, Options{Collapse: []string{"digest"}}, unbatched},
+		{"availability API", 2, Options{Availability: true}, unbatched},
+		{"TimeMap", 2, Options{TimeMap: "https://web.archive.org/web/timemap/link/"}, unbatched},
+		{"memento provider", 2, Options{Provider: ProviderMemento}, unbatched},
+		{"archive.today among other providers", 2, Options{Provider: "wayback,archive.today"}, unbatched},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := GroupByHost(urls, tt.minURLs, tt.opts)
+			if !slices.EqualFunc(got, tt.want, slices.Equal) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLookupHost(t *testing.T) {
+	rows := [][]string{
+		{"urlkey", "timestamp", "original", "mimetype", "statuscode", "digest", "length"},
+		{"example,a)/other", "20100101000000", "https://a.example/other", "text/html", "200", "C", "1"},
+		{"example,a)/page?a=1&b=2", "20050101000000", "http://www.a.example/page?b=2&a=1", "text/html", "200", "B", "1"},
+		{"example,a)/page?a=1&b=2", "20010101000000", "https://a.example/page?a=1&b=2", "text/html", "200", "A", "1"},
+	}
+	var queries []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		queries = append(queries, query.Get("matchType")+" "+query.Get("url"))
+		if query.Get("url") == "down.example" {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(rows)
+	}))
+	defer server.Close()
+	client := NewClient(server.Client())
+	opts := Options{CDXURL: server.URL}
+
+	results := client.LookupHost(context.Background(),
+		[]string{"https://a.example/page?a=1&b=2", "https://A.example/other", "https://a.example/missing"}, opts)
+	want := []struct {
+		status string
+		count  int
+		oldest string // Timestamp of the chosen capture
+	}{
+		{StatusFound, 2, "20010101000000"},
+		{StatusFound, 1, "20100101000000"},
+		{StatusNotFound, 0, ""},
+	}
+	for i, result := range results {
+		if result.Status != want[i].status || result.SnapshotCount != want[i].count ||
+			result.Chosen.Field(FieldTimestamp) != want[i].oldest {
+			t.Errorf("%s: got %s with %d captures, oldest %q; want %s with %d, oldest %q", result.URL,
+				result.Status, result.SnapshotCount, result.Chosen.Field(FieldTimestamp), want[i].status, want[i].count, want[i].oldest)
+		}
+	}
+	if want := []string{"host a.example"}; !slices.Equal(queries, want) {
+		t.Errorf("queried %q, want %q", queries, want)
+	}
+
+	for _, result := range client.LookupHost(context.Background(), []string{"https://down.example/1", "https://down.example/2"}, opts) {
+		if result.Status != StatusError {
+			t.Errorf("%s: got %s after a failed host query, want %s", result.URL, result.Status, StatusError)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package timetraveller
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Cache keeps the captures found for each target URL and set of query options
+// on disk, so lookups repeated within its TTL skip the archive. Entries are
+// written atomically, so several processes may share a cache directory.
+type Cache struct {
+	dir string
+	ttl time.Duration
+}
+
+// NewCache returns a cache storing entries below dir for ttl, or nil (no
+// caching) if dir is empty. The directory is created on first write.
+func NewCache(dir string, ttl time.Duration) *Cache {
+	if dir == "" {
+		return nil
+	}
+	return &Cache{dir: dir, ttl: ttl}
+}
+
+// cacheKey identifies a lookup by its normalized target URL and the options
+// that shape the captures returned.
+func cacheKey(targetURL string, opts Options) string {
+	key, _ := json.Marshal(struct {
+		Target                                            string
+		From, To                                          string
+		Filters, Mimetypes, Collapse, Fields              []string
+		AnyStatus, CountOnly                              bool
+		MatchType, TimeMap, Provider, CDXURL, PlaybackURL string
+		ArchiveItCollection                               string
+		CommonCrawlCrawls                                 int
+	}{
+		NormalizeURL(targetURL),
+		opts.From, opts.To,
+		opts.Filters, opts.Mimetypes, opts.Collapse, opts.Fields,
+		opts.AnyStatus, opts.CountOnly,
+		opts.MatchType, opts.TimeMap, opts.Provider, opts.CDXURL, opts.PlaybackURL,
+		opts.ArchiveItCollection,
+		opts.CommonCrawlCrawls,
+	})
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.dir, key[:2], key+".json")
+}
+
+// get returns the cached captures for key, if present and fresh.
+func (c *Cache) get(key string) ([]SnapshotEntry, bool) {
+	path := c.path(key)
+	info, err := os.Stat(path)
+	if err != nil || (c.ttl > 0 && time.Since(info.ModTime()) > c.ttl) {
+		return nil, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	var snapshots []SnapshotEntry
+	if err := json.Unmarshal(data, &snapshots); err != nil {
+		return nil, false
+	}
+	return snapshots, true
+}
+
+// put stores the captures for key.
+func (c *Cache) put(key string, snapshots []SnapshotEntry) error {
+	data, err := json.Marshal(snapshots)
+	if err != nil {
+		return err
+	}
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), key+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,114 @@
+package timetraveller
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Client looks up snapshots in the Wayback Machine. It is safe for concurrent use.
+type Client struct {
+	HTTPClient *http.Client
+	// Limiter, if set, bounds concurrent requests per host across all lookups.
+	Limiter *HostLimiter
+	// RateLimiter, if set, bounds the overall request rate across all lookups.
+	RateLimiter *RateLimiter
+	// Adaptive, if set, bounds concurrent requests with a limit that shrinks
+	// when the archive rate-limits and slowly grows back.
+	Adaptive *AdaptiveLimiter
+	// Breaker, if set, pauses all requests after a run of consecutive failures.
+	Breaker *CircuitBreaker
+	// OnRateLimited, if set, is called after every rate-limited response with
+	// the number of rate-limited responses since the last successful one.
+	OnRateLimited func(consecutive int)
+	// OnQuery, if set, is called after each provider query that was not
+	// answered from the Cache, with its duration and outcome.
+	OnQuery func(provider string, elapsed time.Duration, err error)
+	// Logger, if set, receives retries, backoff waits and rate-limit hits at
+	// info level, and every request with its duration at debug level.
+	Logger *slog.Logger
+	// Cache, if set, answers repeated lookups from disk.
+	Cache *Cache
+	// ProviderSettings overrides the request policy of the named providers'
+	// queries. It must not be modified once lookups have started.
+	ProviderSettings map[string]ProviderSettings
+
+	rateLimited atomic.Int64 // Rate-limited responses since the last success
+	stats       struct {
+		requests, retries, rateLimited atomic.Int64
+	}
+
+	pauseMu     sync.Mutex
+	pausedUntil map[string]time.Time // Per host, from Retry-After headers
+
+	providersMu sync.Mutex
+	providers   map[string]Provider // Providers created for this client, by name
+}
+
+// NewClient returns a Client that sends requests with httpClient,
+// or http.DefaultClient if it is nil.
+func NewClient(httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{HTTPClient: httpClient}
+}
+
+// Stats counts the requests a Client has sent.
+type Stats struct {
+	Requests    int64 // Requests sent, retries included
+	Retries     int64 // Requests repeating a failed one
+	RateLimited int64 // Rate-limited responses
+}
+
+// Stats returns the request counts of the client so far.
+func (c *Client) Stats() Stats {
+	return Stats{
+		Requests:    c.stats.requests.Load(),
+		Retries:     c.stats.retries.Load(),
+		RateLimited: c.stats.rateLimited.Load(),
+	}
+}
+
+// log writes a message to the client's Logger, if any.
+func (c *Client) log(level slog.Level, msg string, args ...any) {
+	if c.Logger != nil {
+		c.Logger.Log(context.Background(), level, msg, args...)
+	}
+}
+
+// Lookup finds the snapshots of targetURL and selects one according to opts.
+// Failures are reported in the result's Error field.
+func (c *Client) Lookup(ctx context.Context, targetURL string, opts Options) ProcessResult {
+	return c.fetchURLData(ctx, targetURL, opts)
+}
+
+// LookupAll looks up every URL received on urls using the given number of
+// workers, each pausing delay between requests. The returned channel is
+// closed once urls is closed and all lookups have finished.
+func (c *Client) LookupAll(ctx context.Context, urls <-chan string, workers int, delay time.Duration, opts Options) <-chan ProcessResult {
+	results := make(chan ProcessResult, workers)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go c.worker(ctx, urls, results, &wg, delay, opts)
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	return results
+}
+
+func (c *Client) worker(ctx context.Context, urls <-chan string, results chan<- ProcessResult, wg *sync.WaitGroup, delay time.Duration, opts Options) {
+	defer wg.Done()
+	for targetURL := range urls {
+		results <- c.fetchURLData(ctx, targetURL, opts)
+		if delay > 0 {
+			time.Sleep(delay)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package timetraveller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+)
+
+var errCommonCrawlDownload = errors.New("Common Crawl captures are stored in WARC files and cannot be downloaded as snapshots")
+
+// DefaultCommonCrawlCrawls is the number of most recent Common Crawl crawls
+// DefaultOptions queries. Each crawl is a request per lookup.
+const DefaultCommonCrawlCrawls = 3
+
+// commonCrawlProvider queries the Common Crawl index across its crawls.
+type commonCrawlProvider struct {
+	c *Client
+
+	mu     sync.Mutex
+	crawls []string // Index API URLs of the crawls, newest first, once fetched
+}
+
+// indexes returns the index API URLs of the Common Crawl crawls, newest
+// first. The list is fetched once.
+func (p *commonCrawlProvider) indexes(ctx context.Context, opts Options) ([]string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.crawls != nil {
+		return p.crawls, nil
+	}
+
+	body, err := p.c.get(ctx, commonCrawlInfoURL, opts)
+	if err != nil {
+		return nil, err
+	}
+	var collections []struct {
+		CDXAPI string `json:"cdx-api"`
+	}
+	if err := json.Unmarshal(body, &collections); err != nil {
+		return nil, fmt.Errorf("error decoding Common Crawl collection list: %w", err)
+	}
+	for _, collection := range collections {
+		if collection.CDXAPI != "" {
+			p.crawls = append(p.crawls, collection.CDXAPI)
+		}
+	}
+	return p.crawls, nil
+}
+
+// commonCrawlColumns returns the columns from FieldMementoURL on of a record of
+// the crawl index: the index's own record of the capture, which has no
+// playback, then the WARC file holding it and the record's offset there.
+func commonCrawlColumns(index string, record map[string]string) []string {
+	if record["url"] == "" || record["timestamp"] == "" || record["filename"] == "" {
+		return nil
+	}
+	query := url.Values{}
+	query.Set("url", record["url"])
+	query.Set("from", record["timestamp"])
+	query.Set("to", record["timestamp"])
+	query.Set("output", "json")
+	return []string{index + "?" + query.Encode(), commonCrawlDataURL + record["filename"], record["offset"]}
+}
+
+// Query returns the captures of targetURL in the crawls selected by opts, as
+// rows in CDX column order (or opts.Fields order).
+func (p *commonCrawlProvider) Query(ctx context.Context, targetURL string, opts Options) ([]SnapshotEntry, error) {
+	indexes, err := p.indexes(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.CommonCrawlCrawls > 0 && opts.CommonCrawlCrawls < len(indexes) {
+		indexes = indexes[:opts.CommonCrawlCrawls]
+	}
+
+	query := pywbQuery(targetURL, opts)
+	var snapshots []SnapshotEntry
+	for _, index := range indexes {
+		found, err := p.c.fetchPywbIndex(ctx, index, query, pywbFields(opts), opts, func(record map[string]string) []string {
+			return commonCrawlColumns(index, record)
+		})
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, found...)
+	}
+	return snapshots, nil
+}
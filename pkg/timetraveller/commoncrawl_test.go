@@ -0,0 +1,64 @@
+package timetraveller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestCommonCrawlQuery(t *testing.T) {
+	var queried []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("showNumPages") != "" {
+			fmt.Fprint(w, `{"pages": 1}`)
+			return
+		}
+		queried = append(queried, r.URL.Path)
+		fmt.Fprintf(w, `{"urlkey": "com,example)/", "timestamp": "20240101000000", "url": "https://example.com/", "mime": "text/html", "status": "200", "digest": "D", "length": "1234", "offset": "5678", "filename": "crawl-data%s/example.warc.gz"}`+"\n", r.URL.Path)
+	}))
+	defer server.Close()
+	crawls := []string{server.URL + "/CC-4-index", server.URL + "/CC-3-index", server.URL + "/CC-2-index", server.URL + "/CC-1-index"}
+
+	tests := []struct {
+		name   string
+		crawls int
+		want   []string
+	}{
+		{"default", DefaultOptions().CommonCrawlCrawls, []string{"/CC-4-index", "/CC-3-index", "/CC-2-index"}},
+		{"most recent crawl", 1, []string{"/CC-4-index"}},
+		{"every crawl", 0, []string{"/CC-4-index", "/CC-3-index", "/CC-2-index", "/CC-1-index"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queried = nil
+			client := NewClient(server.Client())
+			p := &commonCrawlProvider{c: client, crawls: crawls}
+			snapshots, err := p.Query(context.Background(), "https://example.com/", Options{CommonCrawlCrawls: tt.crawls})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(queried, tt.want) {
+				t.Errorf("queried %q, want %q", queried, tt.want)
+			}
+
+			entry := snapshots[0]
+			wantArchiveURL := crawls[0] + "?from=20240101000000&output=json&to=20240101000000&url=https%3A%2F%2Fexample.com%2F"
+			if got := entry.ArchiveURL(); got != wantArchiveURL {
+				t.Errorf("archive URL %s, want %s", got, wantArchiveURL)
+			}
+			if got, want := entry.Field(FieldWARCFile), commonCrawlDataURL+"crawl-data/CC-4-index/example.warc.gz"; got != want {
+				t.Errorf("WARC file %s, want %s", got, want)
+			}
+			if got := entry.Field(FieldWARCOffset); got != "5678" {
+				t.Errorf("WARC offset %s, want 5678", got)
+			}
+			if _, err := client.Download(context.Background(), entry, 0, Options{}); !errors.Is(err, errCommonCrawlDownload) {
+				t.Errorf("download error %v, want %v", err, errCommonCrawlDownload)
+			}
+		})
+	}
+}
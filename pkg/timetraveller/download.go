@@ -0,0 +1,21 @@
+package timetraveller
+
+import "context"
+
+// Download retrieves the archived body of a snapshot, retrying like CDX
+// queries and sharing the client's per-host limiter. Bodies larger than
+// maxBytes are rejected (0 = unlimited). With opts.Raw, the original bytes are
+// fetched through the id_ modifier.
+func (c *Client) Download(ctx context.Context, entry SnapshotEntry, maxBytes int64, opts Options) ([]byte, error) {
+	archiveURL := entry.ArchiveURL()
+	if opts.Raw {
+		archiveURL = entry.RawURL()
+	}
+	if archiveURL == "" {
+		return nil, errMissingFields
+	}
+	if entry.Field(FieldWARCFile) != "" {
+		return nil, errCommonCrawlDownload
+	}
+	return c.getLimited(ctx, archiveURL, opts, maxBytes)
+}
@@ -0,0 +1,77 @@
+package timetraveller
+
+import (
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// splitHost splits a URL, which may lack a scheme, into the part before its
+// host, the host (with any userinfo and port) and the rest.
+func splitHost(rawURL string) (prefix, host, rest string) {
+	if scheme, after, ok := strings.Cut(rawURL, "://"); ok {
+		prefix, rawURL = scheme+"://", after
+	}
+	end := strings.IndexAny(rawURL, "/?#")
+	if end < 0 {
+		end = len(rawURL)
+	}
+	return prefix, rawURL[:end], rawURL[end:]
+}
+
+// mapHostname applies convert to the hostname of rawURL, leaving any
+// userinfo, port and the rest of the URL as given. The URL is returned
+// unchanged if convert fails.
+func mapHostname(rawURL string, convert func(string) (string, error)) string {
+	prefix, host, rest := splitHost(strings.TrimSpace(rawURL))
+	userinfo := ""
+	if at := strings.LastIndex(host, "@"); at >= 0 {
+		userinfo, host = host[:at+1], host[at+1:]
+	}
+	port := ""
+	if !strings.HasPrefix(host, "[") {
+		if colon := strings.LastIndex(host, ":"); colon >= 0 {
+			host, port = host[:colon], host[colon:]
+		}
+	}
+	wildcard := strings.HasPrefix(host, "*.")
+	host = strings.TrimPrefix(host, "*.")
+	converted, err := convert(host)
+	if err != nil {
+		return rawURL
+	}
+	if wildcard {
+		converted = "*." + converted
+	}
+	return prefix + userinfo + converted + port + rest
+}
+
+// PunycodeURL converts an internationalized hostname in rawURL, which may
+// lack a scheme, to the ASCII punycode form the CDX index keys URLs by, such
+// as "xn--bcher-kva.example" for "bücher.example". ASCII hostnames and those
+// that are not valid IDNs are left as given.
+func PunycodeURL(rawURL string) string {
+	if isASCII(rawURL) {
+		return rawURL
+	}
+	return mapHostname(rawURL, idna.Lookup.ToASCII)
+}
+
+// UnicodeURL converts a punycode hostname in rawURL back to its Unicode
+// form, for display.
+func UnicodeURL(rawURL string) string {
+	if !strings.Contains(strings.ToLower(rawURL), "xn--") {
+		return rawURL
+	}
+	return mapHostname(rawURL, idna.Display.ToUnicode)
+}
+
+// isASCII reports whether s holds only ASCII characters.
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
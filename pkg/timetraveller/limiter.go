@@ -0,0 +1,263 @@
+package timetraveller
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// HostLimiter bounds the number of concurrent requests per host. One limiter is
+// shared by every stage that talks to the network, so CDX queries and any other
+// requests to the same archive host draw from a single budget.
+type HostLimiter struct {
+	limit int
+	mu    sync.Mutex
+	slots map[string]chan struct{}
+}
+
+// NewHostLimiter returns a limiter allowing limit concurrent requests per host,
+// or nil (no limiting) if limit is not positive.
+func NewHostLimiter(limit int) *HostLimiter {
+	if limit <= 0 {
+		return nil
+	}
+	return &HostLimiter{limit: limit, slots: make(map[string]chan struct{})}
+}
+
+// Acquire blocks until a slot for host is free or ctx is done.
+// The returned release function must be called once the request completes.
+func (l *HostLimiter) Acquire(ctx context.Context, host string) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+
+	l.mu.Lock()
+	slot, ok := l.slots[host]
+	if !ok {
+		slot = make(chan struct{}, l.limit)
+		l.slots[host] = slot
+	}
+	l.mu.Unlock()
+
+	select {
+	case slot <- struct{}{}:
+		return func() { <-slot }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// RateLimiter bounds the overall request rate of a client, whatever the number
+// of workers, by spacing requests evenly.
+type RateLimiter struct {
+	interval time.Duration
+	mu       sync.Mutex
+	next     time.Time // Earliest start of the next request
+}
+
+// NewRateLimiter returns a limiter allowing rps requests per second, or nil
+// (no limiting) if rps is not positive.
+func NewRateLimiter(rps float64) *RateLimiter {
+	if rps <= 0 {
+		return nil
+	}
+	return &RateLimiter{interval: time.Duration(float64(time.Second) / rps)}
+}
+
+// Wait blocks until the next request may start or ctx is done.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	start := l.next
+	if start.Before(now) {
+		start = now
+	}
+	l.next = start.Add(l.interval)
+	l.mu.Unlock()
+
+	select {
+	case <-time.After(time.Until(start)):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// AdaptiveLimiter bounds concurrent requests with a limit that adapts to rate
+// limiting: it halves whenever the archive pushes back and grows by one after
+// a full limit's worth of successful requests, up to its maximum.
+type AdaptiveLimiter struct {
+	// OnChange, if set, is called with the new limit whenever it changes.
+	OnChange func(limit int)
+
+	mu        sync.Mutex
+	max       int
+	limit     int
+	inFlight  int
+	successes int
+	changed   chan struct{} // Closed and replaced when a slot may have freed up
+}
+
+// NewAdaptiveLimiter returns a limiter allowing up to max concurrent requests,
+// or nil (no limiting) if max is not positive.
+func NewAdaptiveLimiter(max int) *AdaptiveLimiter {
+	if max <= 0 {
+		return nil
+	}
+	return &AdaptiveLimiter{max: max, limit: max, changed: make(chan struct{})}
+}
+
+// Acquire blocks until a request may start under the current limit or ctx is
+// done. The returned release function must be called once the request
+// completes.
+func (l *AdaptiveLimiter) Acquire(ctx context.Context) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+	for {
+		l.mu.Lock()
+		if l.inFlight < l.limit {
+			l.inFlight++
+			l.mu.Unlock()
+			return l.release, nil
+		}
+		changed := l.changed
+		l.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (l *AdaptiveLimiter) release() {
+	l.mu.Lock()
+	l.inFlight--
+	l.notify()
+	l.mu.Unlock()
+}
+
+// Throttled halves the limit after a rate-limited response.
+func (l *AdaptiveLimiter) Throttled() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.successes = 0
+	if l.limit > 1 {
+		l.setLimit(l.limit / 2)
+	}
+}
+
+// Succeeded records a successful response, growing the limit by one once as
+// many requests as the limit allows have succeeded in a row.
+func (l *AdaptiveLimiter) Succeeded() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.limit >= l.max {
+		return
+	}
+	l.successes++
+	if l.successes >= l.limit {
+		l.successes = 0
+		l.setLimit(l.limit + 1)
+		l.notify()
+	}
+}
+
+// setLimit changes the limit and reports it; l.mu must be held.
+func (l *AdaptiveLimiter) setLimit(limit int) {
+	l.limit = limit
+	if l.OnChange != nil {
+		l.OnChange(limit)
+	}
+}
+
+// notify wakes the requests waiting in Acquire; l.mu must be held.
+func (l *AdaptiveLimiter) notify() {
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// CircuitBreaker pauses every request of a client for a cool-down period once
+// a run of consecutive requests has failed with network errors or 5xx
+// responses, instead of letting each lookup fail in turn.
+type CircuitBreaker struct {
+	// OnOpen, if set, is called with the cool-down whenever the breaker trips.
+	OnOpen func(failures int, cooldown time.Duration)
+
+	threshold int
+	cooldown  time.Duration
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+}
+
+// NewCircuitBreaker returns a breaker that trips after threshold consecutive
+// failures and then holds requests back for cooldown, or nil (never trips) if
+// threshold is not positive.
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	if threshold <= 0 {
+		return nil
+	}
+	return &CircuitBreaker{threshold: threshold, cooldown: cooldown}
+}
+
+// Wait blocks while the breaker is open or until ctx is done.
+func (b *CircuitBreaker) Wait(ctx context.Context) error {
+	if b == nil {
+		return nil
+	}
+	for {
+		b.mu.Lock()
+		wait := time.Until(b.openUntil)
+		b.mu.Unlock()
+		if wait <= 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// Success records a request that reached a healthy server.
+func (b *CircuitBreaker) Success() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	b.failures = 0
+	b.mu.Unlock()
+}
+
+// Failure records a failed request, tripping the breaker at the threshold.
+func (b *CircuitBreaker) Failure() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	if b.failures < b.threshold {
+		return
+	}
+	failures := b.failures
+	b.failures = 0
+	b.openUntil = time.Now().Add(b.cooldown)
+	if b.OnOpen != nil {
+		b.OnOpen(failures, b.cooldown)
+	}
+}
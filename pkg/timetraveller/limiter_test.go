@@ -0,0 +1,146 @@
+package timetraveller
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHostLimiterBoundsLookupsAndDownloads(t *testing.T) {
+	cdx := `[["urlkey","timestamp","original","mimetype","statuscode","digest","length"],` +
+		`["com,example)/","20010101000000","https://example.com/","text/html","200","DIGEST","100"]]`
+	tests := []struct {
+		name      string
+		limit     int
+		lookups   int
+		downloads int
+	}{
+		{"one request at a time", 1, 4, 4},
+		{"lookups and downloads share the slots", 2, 6, 6},
+		{"downloads only", 3, 0, 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var inFlight, peak atomic.Int64
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := inFlight.Add(1)
+				defer inFlight.Add(-1)
+				for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+				}
+				time.Sleep(20 * time.Millisecond)
+				if r.URL.Path == "/cdx" {
+					io.WriteString(w, cdx)
+					return
+				}
+				io.WriteString(w, "<html>archived</html>")
+			}))
+			defer server.Close()
+
+			client := NewClient(server.Client())
+			client.Limiter = NewHostLimiter(tt.limit)
+			opts := Options{CDXURL: server.URL + "/cdx", RetryAttempts: 0}
+			snapshot := SnapshotEntry{"com,example)/", "20010101000000", "https://example.com/", "text/html", "200", "DIGEST", "100",
+				server.URL + "/web/20010101000000/https://example.com/"}
+
+			var wg sync.WaitGroup
+			errs := make(chan error, tt.lookups+tt.downloads)
+			for range tt.lookups {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if result := client.Lookup(context.Background(), "https://example.com/", opts); result.Error != nil {
+						errs <- result.Error
+					}
+				}()
+			}
+			for range tt.downloads {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if _, err := client.Download(context.Background(), snapshot, 0, opts); err != nil {
+						errs <- err
+					}
+				}()
+			}
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				t.Error(err)
+			}
+			if got := peak.Load(); got > int64(tt.limit) {
+				t.Errorf("%d concurrent requests to the host, want at most %d", got, tt.limit)
+			}
+			if got := client.Stats().Requests; got != int64(tt.lookups+tt.downloads) {
+				t.Errorf("%d requests sent, want %d", got, tt.lookups+tt.downloads)
+			}
+		})
+	}
+}
+
+func TestAdaptiveLimiter(t *testing.T) {
+	tests := []struct {
+		name        string
+		max         int
+		events      string // T: rate-limited response, S: successful response
+		wantChanges []int
+	}{
+		{"successes at the maximum change nothing", 8, "SSSS", nil},
+		{"halved on each rate limit", 8, "TTT", []int{4, 2, 1}},
+		{"never below one", 2, "TTT", []int{1}},
+		{"grows by one after as many successes as the limit", 8, "TTSSSS", []int{4, 2, 3}},
+		{"rate limit resets the success count", 8, "TTSTSS", []int{4, 2, 1, 2}},
+		{"grows back up to the maximum only", 4, "TSSSSSSSS", []int{2, 3, 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewAdaptiveLimiter(tt.max)
+			var changes []int
+			l.OnChange = func(limit int) { changes = append(changes, limit) }
+			for _, event := range tt.events {
+				if event == 'T' {
+					l.Throttled()
+				} else {
+					l.Succeeded()
+				}
+			}
+			if !slices.Equal(changes, tt.wantChanges) {
+				t.Errorf("limit changed to %v, want %v", changes, tt.wantChanges)
+			}
+		})
+	}
+}
+
+func TestAdaptiveLimiterAcquire(t *testing.T) {
+	l := NewAdaptiveLimiter(2)
+	l.Throttled() // Limit 1
+	release, err := l.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := l.Acquire(ctx); err == nil {
+		t.Fatal("second request started over the limit of 1")
+	}
+
+	acquired := make(chan struct{})
+	go func() {
+		if _, err := l.Acquire(context.Background()); err == nil {
+			close(acquired)
+		}
+	}()
+	l.Succeeded() // Limit back to 2: the waiting request starts
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("waiting request not started when the limit grew")
+	}
+	release()
+}
@@ -0,0 +1,91 @@
+package timetraveller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Provider is a source of archived captures. Query returns the captures of
+// target matching opts as rows in CDX column order (or opts.Fields order);
+// rows may carry a FieldMementoURL column when the archive is not the Wayback
+// Machine. Implementations must be safe for concurrent use.
+type Provider interface {
+	Query(ctx context.Context, target string, opts Options) ([]SnapshotEntry, error)
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]func(*Client) Provider)
+)
+
+func init() {
+	RegisterProvider(ProviderWayback, func(c *Client) Provider { return &waybackProvider{c: c} })
+	RegisterProvider(ProviderMemento, func(c *Client) Provider { return &timeMapProvider{c, mementoAggregatorURL} })
+	RegisterProvider(ProviderArchiveToday, func(c *Client) Provider { return &timeMapProvider{c, archiveTodayURL} })
+	RegisterProvider(ProviderCommonCrawl, func(c *Client) Provider { return &commonCrawlProvider{c: c} })
+	RegisterProvider(ProviderArquivo, func(c *Client) Provider { return &arquivoProvider{c: c} })
+	RegisterProvider(ProviderArchiveIt, func(c *Client) Provider { return &archiveItProvider{c: c} })
+}
+
+// RegisterProvider makes a provider available under name for Options.Provider.
+// newProvider is called once per Client that uses it; providers normally send
+// their requests through the Client's Get so they share its retries and
+// limiter. Registering a name again replaces the earlier provider.
+func RegisterProvider(name string, newProvider func(*Client) Provider) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[name] = newProvider
+}
+
+// Providers returns the names of the registered providers, sorted.
+func Providers() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// provider returns the client's instance of the named provider, creating it
+// on first use; "" names the Wayback Machine.
+func (c *Client) provider(name string) (Provider, error) {
+	if name == "" {
+		name = ProviderWayback
+	}
+	c.providersMu.Lock()
+	defer c.providersMu.Unlock()
+	if p, ok := c.providers[name]; ok {
+		return p, nil
+	}
+	registryMu.RLock()
+	newProvider, ok := registry[name]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown provider %q", name)
+	}
+	if c.providers == nil {
+		c.providers = make(map[string]Provider)
+	}
+	p := newProvider(c)
+	c.providers[name] = p
+	return p, nil
+}
+
+// ProviderSettings overrides the client's request policy for the queries of
+// one provider, so a multi-provider lookup respects each service's limits.
+type ProviderSettings struct {
+	// RateLimiter, if set, paces the provider's requests in addition to the
+	// client's RateLimiter.
+	RateLimiter *RateLimiter
+	// Timeout bounds each of the provider's requests (0 = the HTTP client's).
+	Timeout time.Duration
+	// RetryAttempts and RetryDelayMs replace those of Options.
+	RetryAttempts int
+	RetryDelayMs  int
+}
@@ -0,0 +1,133 @@
+package timetraveller
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// cdxFieldNames are the CDX API's field names in their default column order.
+var cdxFieldNames = []string{"urlkey", "timestamp", "original", "mimetype", "statuscode", "digest", "length"}
+
+// pywbFieldNames maps CDX API field names to those of pywb-style CDX servers,
+// such as the Common Crawl index and arquivo.pt.
+var pywbFieldNames = map[string]string{"original": "url", "mimetype": "mime", "statuscode": "status"}
+
+// pywbField returns the pywb name of a CDX field.
+func pywbField(name string) string {
+	if pywbName, ok := pywbFieldNames[name]; ok {
+		return pywbName
+	}
+	return name
+}
+
+// pywbFields returns the CDX fields to read from each pywb record.
+func pywbFields(opts Options) []string {
+	if opts.CountOnly {
+		return []string{"timestamp"}
+	}
+	if len(opts.Fields) > 0 {
+		return opts.Fields
+	}
+	return cdxFieldNames
+}
+
+// pywbQuery builds the query parameters of a pywb CDX lookup. Collapse rules
+// and field lists are not supported by pywb servers and are left out.
+func pywbQuery(targetURL string, opts Options) url.Values {
+	query := cdxQuery(targetURL, opts)
+	query.Del("fl")
+	query.Del("collapse")
+	filters := query["filter"]
+	query.Del("filter")
+	for _, filter := range filters {
+		// Filters are "[!]field:regex"; only the field name differs.
+		negate := strings.HasPrefix(filter, "!")
+		name, pattern, _ := strings.Cut(strings.TrimPrefix(filter, "!"), ":")
+		filter = pywbField(name) + ":" + pattern
+		if negate {
+			filter = "!" + filter
+		}
+		query.Add("filter", filter)
+	}
+	return query
+}
+
+// fetchPywbIndex queries a pywb CDX server at index, following its pages, and
+// returns the records as rows of the given CDX fields. When all default fields
+// are read, extra supplies each row's columns from FieldMementoURL on.
+func (c *Client) fetchPywbIndex(ctx context.Context, index string, query url.Values, fields []string, opts Options, extra func(record map[string]string) []string) ([]SnapshotEntry, error) {
+	pages := 1
+	pageQuery := url.Values{}
+	for name, values := range query {
+		pageQuery[name] = values
+	}
+	pageQuery.Set("showNumPages", "true")
+	body, err := c.get(ctx, index+"?"+pageQuery.Encode(), opts)
+	if err != nil {
+		return nil, ignoreNotFound(err)
+	}
+	var info struct {
+		Pages int `json:"pages"`
+	}
+	if json.Unmarshal(body, &info) == nil && info.Pages > 1 {
+		pages = info.Pages
+	}
+	pageQuery.Del("showNumPages")
+
+	var snapshots []SnapshotEntry
+	for page := 0; page < pages; page++ {
+		pageQuery.Set("page", strconv.Itoa(page))
+		body, err := c.get(ctx, index+"?"+pageQuery.Encode(), opts)
+		if err != nil {
+			if err = ignoreNotFound(err); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		snapshots = append(snapshots, parsePywbRecords(body, fields, extra)...)
+	}
+	return snapshots, nil
+}
+
+// parsePywbRecords decodes pywb's JSON output, one object per line, into rows
+// of the given CDX fields. When all default fields are read and extra is not
+// nil, it supplies each row's columns from FieldMementoURL on.
+func parsePywbRecords(body []byte, fields []string, extra func(record map[string]string) []string) []SnapshotEntry {
+	var snapshots []SnapshotEntry
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var record map[string]string
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			continue
+		}
+		row := make(SnapshotEntry, len(fields))
+		for i, name := range fields {
+			row[i] = record[pywbField(name)]
+		}
+		if extra != nil && len(fields) == len(cdxFieldNames) {
+			for _, column := range extra(record) {
+				row = append(row, column)
+			}
+		}
+		snapshots = append(snapshots, row)
+	}
+	return snapshots
+}
+
+// ignoreNotFound drops the 404 pywb servers answer when they hold no
+// captures, returning nil for it and err otherwise.
+func ignoreNotFound(err error) error {
+	var status *statusError
+	if errors.As(err, &status) && status.code == http.StatusNotFound {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,94 @@
+package timetraveller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// savePollInterval is how often SavePage checks on a pending capture.
+const savePollInterval = 5 * time.Second
+
+// saveResponse is the JSON returned by the Save Page Now API, both when a
+// capture is submitted and when its status is polled.
+type saveResponse struct {
+	JobID       string `json:"job_id"`
+	Status      string `json:"status"`
+	Message     string `json:"message"`
+	Timestamp   string `json:"timestamp"`
+	OriginalURL string `json:"original_url"`
+}
+
+// SavePage asks the Wayback Machine's Save Page Now (SPN2) API to capture
+// targetURL and waits for the capture to finish, returning its archive URL.
+// apiKey is an optional "accesskey:secret" pair from archive.org/account/s3.php;
+// authenticated requests get higher limits. Waiting stops when ctx is done.
+func (c *Client) SavePage(ctx context.Context, targetURL, apiKey string) (string, error) {
+	form := url.Values{}
+	form.Set("url", targetURL)
+	req, err := http.NewRequestWithContext(ctx, "POST", saveAPIURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if apiKey != "" {
+		req.Header.Set("Authorization", "LOW "+apiKey)
+	}
+
+	release, err := c.acquire(ctx, req.URL.Host, Options{})
+	if err != nil {
+		return "", err
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		release()
+		return "", fmt.Errorf("error submitting capture: %w", err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	release()
+	if err != nil {
+		return "", fmt.Errorf("error reading response body: %w", err)
+	}
+	var submitted saveResponse
+	if err := json.Unmarshal(body, &submitted); err != nil || submitted.JobID == "" {
+		if submitted.Message != "" {
+			return "", fmt.Errorf("capture refused: %s", submitted.Message)
+		}
+		return "", fmt.Errorf("capture refused. Status: %s", resp.Status)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("aborted while waiting for capture: %w", ctx.Err())
+		case <-time.After(savePollInterval):
+		}
+		body, err := c.get(ctx, saveAPIURL+"/status/"+url.PathEscape(submitted.JobID), DefaultOptions())
+		if err != nil {
+			return "", err
+		}
+		var status saveResponse
+		if err := json.Unmarshal(body, &status); err != nil {
+			return "", fmt.Errorf("error decoding JSON response: %w", err)
+		}
+		switch status.Status {
+		case "pending":
+			continue
+		case "success":
+			original := status.OriginalURL
+			if original == "" {
+				original = targetURL
+			}
+			return SnapshotEntry{"", status.Timestamp, original}.ArchiveURL(), nil
+		default:
+			return "", fmt.Errorf("capture failed: %s", status.Message)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package timetraveller
+
+import (
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var (
+	// wwwPrefix matches the "www", "www2", ... labels the index ignores.
+	wwwPrefix = regexp.MustCompile(`^www\d*\.`)
+	// pathSessionID matches session IDs carried as path parameters.
+	pathSessionID = regexp.MustCompile(`;(jsessionid|phpsessid|sid|sessionid)=[^/?]*`)
+)
+
+// isSessionParam reports whether a query parameter name holds a session ID.
+func isSessionParam(name string) bool {
+	switch name {
+	case "jsessionid", "phpsessid", "sid", "sessionid", "cfid", "cftoken":
+		return true
+	}
+	return strings.HasPrefix(name, "aspsessionid")
+}
+
+// Canonicalize returns rawURL in the canonical form the Wayback Machine keys
+// its index by, but without reversing the host: lowercased, without scheme,
+// "www" prefix, default port, fragment or session IDs, and with the query
+// parameters sorted. Equivalent URLs canonicalize to the same string, which
+// is still a valid lookup target.
+func Canonicalize(rawURL string) string {
+	rawURL = strings.TrimSpace(rawURL)
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "http://" + rawURL
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return strings.ToLower(rawURL)
+	}
+
+	host := wwwPrefix.ReplaceAllString(strings.ToLower(u.Hostname()), "")
+	if port := u.Port(); port != "" && !(port == "80" && u.Scheme == "http") && !(port == "443" && u.Scheme == "https") {
+		host += ":" + port
+	}
+
+	path := pathSessionID.ReplaceAllString(strings.ToLower(u.EscapedPath()), "")
+	if path == "" {
+		path = "/"
+	}
+
+	var params []string
+	for _, param := range strings.Split(strings.ToLower(u.RawQuery), "&") {
+		name, _, _ := strings.Cut(param, "=")
+		if param != "" && !isSessionParam(name) {
+			params = append(params, param)
+		}
+	}
+	slices.Sort(params)
+
+	canonical := host + path
+	if len(params) > 0 {
+		canonical += "?" + strings.Join(params, "&")
+	}
+	return canonical
+}
+
+// SURT returns the Sort-friendly URI Reordering Transform of rawURL's
+// canonical form, such as "com,example)/path?a=1" for
+// "https://www.example.com/path?a=1": the urlkey column of CDX rows.
+func SURT(rawURL string) string {
+	canonical := Canonicalize(rawURL)
+	end := strings.IndexAny(canonical, "/?")
+	if end < 0 {
+		end = len(canonical)
+	}
+	host, port, hasPort := strings.Cut(canonical[:end], ":")
+	labels := strings.Split(host, ".")
+	slices.Reverse(labels)
+	key := strings.Join(labels, ",")
+	if hasPort {
+		key += ":" + port
+	}
+	return key + ")" + canonical[end:]
+}
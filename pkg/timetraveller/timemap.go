@@ -0,0 +1,132 @@
+package timetraveller
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+var errTimeMapExactOnly = errors.New("TimeMap lookups only support exact URLs without a field list")
+
+// link is one entry of an application/link-format document (RFC 6690).
+type link struct {
+	url    string
+	params map[string]string
+}
+
+// hasRel reports whether the link's rel parameter includes rel.
+func (l link) hasRel(rel string) bool {
+	for _, r := range strings.Fields(l.params["rel"]) {
+		if r == rel {
+			return true
+		}
+	}
+	return false
+}
+
+// parseLinkFormat parses an application/link-format body into its links.
+// Commas inside <...> targets and quoted parameter values do not split links.
+func parseLinkFormat(body string) []link {
+	var links []link
+	for {
+		start := strings.IndexByte(body, '<')
+		if start < 0 {
+			return links
+		}
+		end := strings.IndexByte(body[start:], '>')
+		if end < 0 {
+			return links
+		}
+		l := link{url: body[start+1 : start+end], params: make(map[string]string)}
+		body = body[start+end+1:]
+
+		// Parameters run until the next comma outside quotes.
+		inQuotes := false
+		i := 0
+		for ; i < len(body); i++ {
+			if body[i] == '"' {
+				inQuotes = !inQuotes
+			} else if body[i] == ',' && !inQuotes {
+				break
+			}
+		}
+		for _, param := range strings.Split(body[:i], ";") {
+			name, value, ok := strings.Cut(param, "=")
+			if !ok {
+				continue
+			}
+			l.params[strings.ToLower(strings.TrimSpace(name))] = strings.Trim(strings.TrimSpace(value), `"`)
+		}
+		links = append(links, l)
+		body = body[i:]
+	}
+}
+
+// timeMapProvider reads captures from a Memento TimeMap endpoint, the prefix
+// the target URL is appended to.
+type timeMapProvider struct {
+	c        *Client
+	endpoint string
+}
+
+// Query returns the captures of targetURL listed by the TimeMap, following
+// rel="next" links to later pages of paged TimeMaps.
+func (p *timeMapProvider) Query(ctx context.Context, targetURL string, opts Options) ([]SnapshotEntry, error) {
+	if (opts.MatchType != "" && opts.MatchType != MatchExact) || len(opts.Fields) > 0 {
+		return nil, errTimeMapExactOnly
+	}
+	var snapshots []SnapshotEntry
+	seen := make(map[string]bool)
+	next := p.endpoint + targetURL
+	for next != "" && !seen[next] {
+		seen[next] = true
+		body, err := p.c.get(ctx, next, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		next = ""
+		original := targetURL
+		links := parseLinkFormat(string(body))
+		for _, l := range links {
+			if l.hasRel("original") {
+				original = l.url
+			}
+		}
+		for _, l := range links {
+			if l.hasRel("next") && strings.Contains(l.params["type"], "link-format") {
+				next = l.url
+			}
+			if !l.hasRel("memento") {
+				continue
+			}
+			captured, err := http.ParseTime(l.params["datetime"])
+			if err != nil {
+				continue
+			}
+			timestamp := captured.UTC().Format("20060102150405")
+			if !inRange(timestamp, opts.From, opts.To) {
+				continue
+			}
+			snapshots = append(snapshots, SnapshotEntry{"", timestamp, original, "", "", "", "", l.url})
+		}
+	}
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		return snapshots[i].Field(FieldTimestamp) < snapshots[j].Field(FieldTimestamp)
+	})
+	return snapshots, nil
+}
+
+// inRange reports whether a full timestamp falls within the partial from and
+// to bounds, which are inclusive like the CDX API's ("" leaves a side open).
+func inRange(timestamp, from, to string) bool {
+	if from != "" && timestamp < from {
+		return false
+	}
+	if to != "" && len(to) <= len(timestamp) && timestamp[:len(to)] > to {
+		return false
+	}
+	return true
+}
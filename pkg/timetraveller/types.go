@@ -0,0 +1,270 @@
+// Package timetraveller looks up archived snapshots of URLs through the
+// Wayback Machine CDX and availability APIs.
+package timetraveller
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	cdxAPIURL            = "https://web.archive.org/cdx/search/cdx"
+	availabilityAPIURL   = "https://archive.org/wayback/available"
+	mementoAggregatorURL = "https://timetravel.mementoweb.org/timemap/link/"
+	archiveTodayURL      = "https://archive.ph/timemap/"
+	commonCrawlInfoURL   = "https://index.commoncrawl.org/collinfo.json"
+	commonCrawlDataURL   = "https://data.commoncrawl.org/"
+	arquivoCDXURL        = "https://arquivo.pt/wayback/cdx"
+	arquivoPlaybackURL   = "https://arquivo.pt/wayback/"
+	saveAPIURL           = "https://web.archive.org/save"
+	archiveItURL         = "https://wayback.archive-it.org/"
+)
+
+// Snapshot providers, selecting which archives a lookup consults.
+const (
+	ProviderWayback      = "wayback"       // The Wayback Machine's CDX API
+	ProviderMemento      = "memento"       // The Memento aggregator, covering many web archives
+	ProviderArchiveToday = "archive.today" // archive.today (archive.ph), which keeps many pages gone from Wayback
+	ProviderCommonCrawl  = "commoncrawl"   // The Common Crawl index; captures are listed but cannot be downloaded
+	ProviderArquivo      = "arquivo"       // arquivo.pt, the Portuguese web archive, strong on European sites
+	ProviderArchiveIt    = "archive-it"    // An Archive-It collection, see Options.ArchiveItCollection
+)
+
+var errMissingFields = errors.New("snapshot entry lacks a timestamp or original URL")
+
+// Result statuses.
+const (
+	StatusFound    = "found"
+	StatusNotFound = "not found"
+	StatusError    = "error"
+)
+
+// CDX match types, selecting which archived URLs a query covers.
+const (
+	MatchExact  = "exact"  // Only the given URL
+	MatchPrefix = "prefix" // All URLs starting with the given URL
+	MatchHost   = "host"   // All URLs on the given host
+	MatchDomain = "domain" // All URLs on the given host and its subdomains
+)
+
+// Column positions of the default CDX JSON output.
+const (
+	FieldURLKey = iota
+	FieldTimestamp
+	FieldOriginal
+	FieldMimetype
+	FieldStatusCode
+	FieldDigest
+	FieldLength
+	// FieldMementoURL is an extra column holding the archive's own URL of the
+	// capture, set on snapshots read from a Memento TimeMap or from archives
+	// other than the Wayback Machine.
+	FieldMementoURL
+	// FieldWARCFile and FieldWARCOffset locate the WARC record of a capture
+	// read from the Common Crawl index, which has no playback: the URL of the
+	// WARC file and the record's byte offset in it. FieldLength is its length.
+	FieldWARCFile
+	FieldWARCOffset
+)
+
+// waybackHost is the archive reported for snapshots read from the CDX API.
+const waybackHost = "web.archive.org"
+
+// SnapshotEntry defines the structure of a single entry from CDX API (partially).
+type SnapshotEntry []interface{}
+
+// Field returns the string value of column i, or "" if it is missing or not a string.
+func (e SnapshotEntry) Field(i int) string {
+	if i >= len(e) {
+		return ""
+	}
+	v, _ := e[i].(string)
+	return v
+}
+
+// ArchiveURL returns the playback URL of the snapshot, or "" if the entry lacks
+// a timestamp or original URL.
+func (e SnapshotEntry) ArchiveURL() string {
+	if memento := e.Field(FieldMementoURL); memento != "" {
+		return memento
+	}
+	timestamp, original := e.Field(FieldTimestamp), e.Field(FieldOriginal)
+	if timestamp == "" || original == "" {
+		return ""
+	}
+	return fmt.Sprintf("http://web.archive.org/web/%s/%s", timestamp, original)
+}
+
+// Archive returns the host of the archive holding the snapshot.
+func (e SnapshotEntry) Archive() string {
+	memento := e.Field(FieldMementoURL)
+	if memento == "" {
+		return waybackHost
+	}
+	if u, err := url.Parse(memento); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return ""
+}
+
+// RawURL returns the playback URL with the id_ modifier, which serves the
+// originally captured bytes without the Wayback Machine's rewriting and toolbar.
+// Memento URLs get the modifier too when they follow the Wayback layout of a
+// collection path before the timestamp ("/web/<timestamp>/"); archives such
+// as archive.today, whose paths start with the timestamp, have no raw mode.
+func (e SnapshotEntry) RawURL() string {
+	timestamp, original := e.Field(FieldTimestamp), e.Field(FieldOriginal)
+	if memento := e.Field(FieldMementoURL); memento != "" {
+		u, err := url.Parse(memento)
+		if err != nil || timestamp == "" {
+			return memento
+		}
+		if i := strings.Index(u.Path, "/"+timestamp+"/"); i > 0 {
+			end := i + 1 + len(timestamp)
+			u.Path = u.Path[:end] + "id_" + u.Path[end:]
+			u.RawPath = ""
+			return u.String()
+		}
+		return memento
+	}
+	if timestamp == "" || original == "" {
+		return ""
+	}
+	return fmt.Sprintf("http://web.archive.org/web/%sid_/%s", timestamp, original)
+}
+
+// Options controls how a lookup queries the CDX API and picks a snapshot.
+type Options struct {
+	Latest         bool
+	Closest        string // Select the capture nearest this timestamp instead of oldest/latest
+	RetryAttempts  int
+	RetryDelayMs   int
+	MaxBackoffMs   int      // Cap on the delay before a retry; 0 means no cap
+	MimePreference []string // Preferred mimetypes, most preferred first
+	RetryOnBody    []string // Substrings that mark a 200 response as a transient failure
+	DetailsLink    bool     // Also build the archive's calendar/details link
+	From           string   // Earliest capture timestamp to consider (YYYY[MM[DD...]])
+	To             string   // Latest capture timestamp to consider (YYYY[MM[DD...]])
+	Filters        []string // CDX filter expressions; replace the default statuscode:200 filter
+	AnyStatus      bool     // Consider captures with any HTTP status, not only 200
+	Mimetypes      []string // Only consider captures with one of these mimetypes
+	Collapse       []string // CDX collapse rules such as "timestamp:4" or "digest"; ignored by pywb-based providers
+	PageSize       int      // Rows requested per CDX page; 0 means 10000 from the Wayback Machine, the API's default elsewhere
+	CountOnly      bool     // Only count captures; no snapshot is selected or kept
+	MatchType      string   // One of the Match* constants; "" means exact
+	// Fields limits the CDX columns returned (the "fl" parameter). Rows then hold
+	// only these columns, in order, and no snapshot is selected.
+	Fields []string
+	Raw    bool // Download original bytes (id_ modifier) instead of the rewritten playback page
+	// Availability answers lookups with the lightweight availability API
+	// instead of the CDX API. Only the chosen snapshot is returned, with no
+	// count or history, and the query options (filters, dates, match type,
+	// collapsing, paging) are ignored.
+	Availability bool
+	// TimeMap, if set, reads captures from a Memento TimeMap instead of the CDX
+	// API. It is the TimeMap endpoint prefix the target URL is appended to, such
+	// as "https://web.archive.org/web/timemap/link/". Only From and To are
+	// applied to the captures; other query options are ignored.
+	TimeMap string
+	// Provider selects the archives to consult; one of the Provider* constants,
+	// "" means the Wayback Machine. Several providers may be joined with commas,
+	// merging their captures. Ignored when TimeMap is set.
+	Provider string
+	// CDXURL is the CDX API endpoint of the wayback provider, for self-hosted
+	// pywb or OpenWayback instances; "" means the Wayback Machine's.
+	CDXURL string
+	// PlaybackURL is the prefix of snapshot URLs served by CDXURL's archive,
+	// such as "http://localhost:8080/my-coll/"; the timestamp and original URL
+	// are appended. "" means the Wayback Machine's.
+	PlaybackURL string
+	// ArchiveItCollection is the ID of the Archive-It collection queried by
+	// ProviderArchiveIt.
+	ArchiveItCollection string
+	// ArchiveItAuth is an optional "user:password" pair for private Archive-It
+	// collections, sent as basic auth to archive-it.org hosts only.
+	ArchiveItAuth string
+	// CommonCrawlCrawls is the number of most recent Common Crawl crawls to
+	// query with ProviderCommonCrawl; 0 queries every crawl, about a hundred
+	// requests per lookup.
+	CommonCrawlCrawls int
+
+	// settings is the ProviderSettings of the provider being queried, if any.
+	settings *ProviderSettings
+}
+
+// DefaultOptions returns the options used by the command-line tool.
+func DefaultOptions() Options {
+	return Options{
+		RetryAttempts:     3,
+		RetryDelayMs:      5000,
+		MaxBackoffMs:      60000,
+		CommonCrawlCrawls: DefaultCommonCrawlCrawls,
+	}
+}
+
+// ProcessResult holds the outcome of processing a single URL.
+type ProcessResult struct {
+	URL           string
+	Status        string // StatusFound, StatusNotFound or StatusError
+	SnapshotCount int
+	OldestURL     string
+	Chosen        SnapshotEntry // The selected capture, if any
+	DetailsURL    string        // Wayback calendar view around the chosen capture, if requested
+	Snapshots     []SnapshotEntry
+	Error         error // Holds any error encountered during processing
+}
+
+// LatestTimestamp returns the timestamp of the most recent snapshot in the
+// result, or "" if it has none.
+func (r ProcessResult) LatestTimestamp() string {
+	if len(r.Snapshots) == 0 {
+		return ""
+	}
+	return r.Snapshots[len(r.Snapshots)-1].Field(FieldTimestamp)
+}
+
+// Changes returns the snapshots at which the result's content changed: the
+// first capture of every run of consecutive captures sharing a digest.
+func (r ProcessResult) Changes() []SnapshotEntry {
+	var changes []SnapshotEntry
+	previous := ""
+	for i, entry := range r.Snapshots {
+		digest := entry.Field(FieldDigest)
+		if i == 0 || digest != previous {
+			changes = append(changes, entry)
+		}
+		previous = digest
+	}
+	return changes
+}
+
+// ParseTimestamp parses a full or partial CDX timestamp (YYYY[MM[DD[hh[mm[ss]]]]]).
+// Missing components default to the start of the period.
+func ParseTimestamp(ts string) (time.Time, error) {
+	const layout = "20060102150405"
+	const defaults = "19700101000000"
+	if len(ts) < 4 || len(ts) > len(layout) {
+		return time.Time{}, fmt.Errorf("invalid timestamp %q: expected YYYY, YYYYMM, YYYYMMDD or longer", ts)
+	}
+	return time.Parse(layout, ts+defaults[len(ts):])
+}
+
+// NormalizeURL lowercases the case-insensitive scheme and host of a URL,
+// which may lack a scheme, leaving the rest as given.
+func NormalizeURL(rawURL string) string {
+	rawURL = strings.TrimSpace(rawURL)
+	scheme, rest, ok := strings.Cut(rawURL, "://")
+	if ok {
+		scheme = strings.ToLower(scheme) + "://"
+	} else {
+		scheme, rest = "", rawURL
+	}
+	end := strings.IndexAny(rest, "/?#")
+	if end < 0 {
+		end = len(rest)
+	}
+	return scheme + strings.ToLower(rest[:end]) + rest[end:]
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/aleister1102/timetraveller/pkg/timetraveller"
+)
+
+// progressInterval is how often the progress line is redrawn.
+const progressInterval = 250 * time.Millisecond
+
+// isTerminal reports whether file is a terminal.
+func isTerminal(file *os.File) bool {
+	stat, _ := file.Stat()
+	return stat != nil && stat.Mode()&os.ModeCharDevice != 0
+}
+
+// progress draws a live status line on stderr: URLs processed out of those
+// queued so far, result counts, rate and, once the input has ended, the
+// estimated time left. It is drawn by the goroutine reading results through
+// next, between result lines, so it never interleaves with them. A nil
+// progress draws nothing.
+type progress struct {
+	queued    atomic.Int64
+	inputDone atomic.Bool
+	processed int
+	found     int
+	notFound  int
+	failed    int
+	start     time.Time
+	ticker    *time.Ticker
+	drawn     bool
+}
+
+// newProgress returns a progress line, or nil if stderr is not a terminal.
+func newProgress() *progress {
+	if !isTerminal(os.Stderr) {
+		return nil
+	}
+	return &progress{start: time.Now(), ticker: time.NewTicker(progressInterval)}
+}
+
+// count passes on the URLs of in, counting them as queued.
+func (p *progress) count(in <-chan string) <-chan string {
+	if p == nil {
+		return in
+	}
+	out := make(chan string)
+	go func() {
+		defer close(out)
+		for u := range in {
+			p.queued.Add(1)
+			out <- u
+		}
+		p.inputDone.Store(true)
+	}()
+	return out
+}
+
+// next receives the next result, redrawing the progress line while it
+// waits. The line is cleared before returning, so the result can be printed.
+func (p *progress) next(results <-chan timetraveller.ProcessResult) (timetraveller.ProcessResult, bool) {
+	if p == nil {
+		result, ok := <-results
+		return result, ok
+	}
+	for {
+		select {
+		case result, ok := <-results:
+			p.clear()
+			if ok {
+				p.add(result)
+			}
+			return result, ok
+		case <-p.ticker.C:
+			p.draw()
+		}
+	}
+}
+
+// add counts a result.
+func (p *progress) add(result timetraveller.ProcessResult) {
+	p.processed++
+	switch {
+	case result.Error != nil:
+		p.failed++
+	case result.Status == timetraveller.StatusFound:
+		p.found++
+	default:
+		p.notFound++
+	}
+}
+
+// draw writes the progress line over the previous one.
+func (p *progress) draw() {
+	elapsed := time.Since(p.start)
+	rate := float64(p.processed) / elapsed.Seconds()
+	total := fmt.Sprintf("%d+", p.queued.Load())
+	eta := "?"
+	if p.inputDone.Load() {
+		total = total[:len(total)-1]
+		if rate > 0 {
+			left := float64(p.queued.Load()-int64(p.processed)) / rate
+			eta = (time.Duration(left) * time.Second).String()
+		}
+	}
+	fmt.Fprintf(os.Stderr, "\r\033[K[~] %d/%s processed | %d found, %d not found, %d errors | %.1f/s | ETA %s",
+		p.processed, total, p.found, p.notFound, p.failed, rate, eta)
+	p.drawn = true
+}
+
+// clear erases the progress line, if drawn.
+func (p *progress) clear() {
+	if p == nil || !p.drawn {
+		return
+	}
+	fmt.Fprint(os.Stderr, "\r\033[K")
+	p.drawn = false
+}
+
+// Close erases the progress line and stops redrawing it.
+func (p *progress) Close() {
+	if p == nil {
+		return
+	}
+	p.clear()
+	p.ticker.Stop()
+}
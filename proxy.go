@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// parseProxyURL parses a proxy given as an http://, https:// or socks5:// URL.
+func parseProxyURL(value string) (*url.URL, error) {
+	u, err := url.Parse(value)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q: expected http, https or socks5", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("proxy URL %q lacks a host", value)
+	}
+	return u, nil
+}
+
+// loadProxies reads the proxy URLs listed one per line in filename, skipping
+// blank lines and # comments.
+func loadProxies(filename string) ([]*url.URL, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var proxies []*url.URL
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		u, err := parseProxyURL(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		proxies = append(proxies, u)
+	}
+	if len(proxies) == 0 {
+		return nil, fmt.Errorf("no proxies listed")
+	}
+	return proxies, nil
+}
+
+// proxyRotator spreads requests across proxies, in turn or at random.
+type proxyRotator struct {
+	proxies []*url.URL
+	random  bool
+	next    atomic.Uint64
+}
+
+// proxy picks the proxy of a request; it is an http.Transport Proxy func.
+func (r *proxyRotator) proxy(*http.Request) (*url.URL, error) {
+	if r.random {
+		return r.proxies[rand.IntN(len(r.proxies))], nil
+	}
+	return r.proxies[(r.next.Add(1)-1)%uint64(len(r.proxies))], nil
+}
+
+// torProxy routes requests through a Tor SOCKS port. Tor keeps streams with
+// different SOCKS credentials on different circuits, so changing the
+// credentials moves new connections onto a new circuit and exit node.
+type torProxy struct {
+	addr    string
+	circuit atomic.Int64
+}
+
+// proxy returns the SOCKS URL of the current circuit; it is an
+// http.Transport Proxy func.
+func (t *torProxy) proxy(*http.Request) (*url.URL, error) {
+	return &url.URL{
+		Scheme: "socks5h",
+		Host:   t.addr,
+		User:   url.UserPassword("timetraveller", strconv.FormatInt(t.circuit.Load(), 10)),
+	}, nil
+}
+
+// newCircuit moves subsequent connections onto a new circuit.
+func (t *torProxy) newCircuit() {
+	t.circuit.Add(1)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"strings"
+	"time"
+)
+
+// checkpointInterval is how often a checkpoint is written out.
+const checkpointInterval = 5 * time.Second
+
+// checkpoint records the input URLs a run has completed, one per line, so a
+// run started again with -resume can skip them.
+type checkpoint struct {
+	completed map[string]bool
+	file      *os.File
+	writer    *bufio.Writer
+	synced    time.Time
+}
+
+// openCheckpoint reads the URLs completed by earlier runs from filename, if
+// it exists, and opens it to record further ones.
+func openCheckpoint(filename string) (*checkpoint, error) {
+	c := &checkpoint{completed: make(map[string]bool), synced: time.Now()}
+	data, err := os.ReadFile(filename)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		// A run killed mid-write can leave a partial last line; it never
+		// matches an input URL exactly unless it was complete.
+		if line != "" {
+			c.completed[line] = true
+		}
+	}
+	if c.file, err = os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644); err != nil {
+		return nil, err
+	}
+	if len(data) > 0 && !strings.HasSuffix(string(data), "\n") {
+		// Start on a fresh line after a partial one.
+		if _, err := c.file.WriteString("\n"); err != nil {
+			c.file.Close()
+			return nil, err
+		}
+	}
+	c.writer = bufio.NewWriter(c.file)
+	return c, nil
+}
+
+// add records targetURL as completed. It reaches the file on the next sync.
+func (c *checkpoint) add(targetURL string) error {
+	_, err := c.writer.WriteString(targetURL + "\n")
+	return err
+}
+
+// due reports whether a sync is due.
+func (c *checkpoint) due() bool {
+	return time.Since(c.synced) >= checkpointInterval
+}
+
+// sync writes the recorded URLs out to the file.
+func (c *checkpoint) sync() error {
+	c.synced = time.Now()
+	if err := c.writer.Flush(); err != nil {
+		return err
+	}
+	return c.file.Sync()
+}
+
+// Close syncs the checkpoint and closes its file.
+func (c *checkpoint) Close() error {
+	err := c.sync()
+	if closeErr := c.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
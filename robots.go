@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+)
+
+// disallowedPaths returns the paths of the Disallow rules in a robots.txt body.
+func disallowedPaths(body []byte) []string {
+	var paths []string
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if !ok || !strings.EqualFold(strings.TrimSpace(name), "disallow") {
+			continue
+		}
+		if path := strings.TrimSpace(value); path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// runRobots prints every Disallow path listed by the archived versions of
+// each host's robots.txt.
+func runRobots(args []string) {
+	runWellKnown(wellKnownFile{
+		command: "robots",
+		path:    "/robots.txt",
+		entries: "disallowed paths",
+		maxSize: 1 << 20,
+		extract: disallowedPaths,
+	}, args)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	// saveTimeout bounds how long -save-missing waits for one capture.
+	saveTimeout = 3 * time.Minute
+	// saveWorkers is how many -save-missing captures run at once. Save Page
+	// Now allows few concurrent captures per user, so more would be refused.
+	saveWorkers = 4
+)
+
+// savePool submits the captures of -save-missing on a few workers, so slow
+// captures do not hold up the results of other lookups. Finished captures are
+// handed back to the goroutine calling its methods, which alone writes them.
+type savePool struct {
+	jobs  chan checkResult
+	saved chan checkResult
+}
+
+// newSavePool starts workers capturing each submitted result's URL with save,
+// under a timeout; captures still running when ctx is done are aborted.
+func newSavePool(ctx context.Context, workers int, save func(ctx context.Context, url string) (string, error)) *savePool {
+	p := &savePool{jobs: make(chan checkResult), saved: make(chan checkResult)}
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for out := range p.jobs {
+				saveCtx, cancel := context.WithTimeout(ctx, saveTimeout)
+				out.SavedURL, out.SaveErr = save(saveCtx, out.URL)
+				cancel()
+				p.saved <- out
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(p.saved)
+	}()
+	return p
+}
+
+// submit queues out for capture once a worker is free, calling emit with the
+// captures finishing meanwhile.
+func (p *savePool) submit(out checkResult, emit func(checkResult)) {
+	for {
+		select {
+		case p.jobs <- out:
+			return
+		case saved := <-p.saved:
+			emit(saved)
+		}
+	}
+}
+
+// poll calls emit with the captures that have finished, without waiting.
+func (p *savePool) poll(emit func(checkResult)) {
+	for {
+		select {
+		case saved := <-p.saved:
+			emit(saved)
+		default:
+			return
+		}
+	}
+}
+
+// drain waits for every queued capture, calling emit with each. No capture
+// may be submitted afterwards.
+func (p *savePool) drain(emit func(checkResult)) {
+	close(p.jobs)
+	for saved := range p.saved {
+		emit(saved)
+	}
+}
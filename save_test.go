@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aleister1102/timetraveller/pkg/timetraveller"
+)
+
+func TestSavePool(t *testing.T) {
+	tests := []struct {
+		name     string
+		workers  int
+		urls     int
+		cancel   bool // Cancel the run's requests before saving
+		wantSave bool
+	}{
+		{"one worker", 1, 3, false, true},
+		{"captures bounded by the workers", 3, 10, false, true},
+		{"captures aborted with the run", 2, 4, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancel {
+				cancel()
+			}
+			var inFlight, peak atomic.Int64
+			pool := newSavePool(ctx, tt.workers, func(ctx context.Context, u string) (string, error) {
+				n := inFlight.Add(1)
+				defer inFlight.Add(-1)
+				for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+				}
+				select {
+				case <-time.After(10 * time.Millisecond):
+					return "http://web.archive.org/web/20260101000000/" + u, nil
+				case <-ctx.Done():
+					return "", ctx.Err()
+				}
+			})
+
+			var want, got []string
+			emit := func(out checkResult) {
+				got = append(got, out.URL)
+				if saved := out.SaveErr == nil && out.SavedURL == "http://web.archive.org/web/20260101000000/"+out.URL; saved != tt.wantSave {
+					t.Errorf("%s: saved %q with error %v", out.URL, out.SavedURL, out.SaveErr)
+				}
+				if !tt.wantSave && !errors.Is(out.SaveErr, context.Canceled) {
+					t.Errorf("%s: error %v, want the run's cancellation", out.URL, out.SaveErr)
+				}
+			}
+			for i := range tt.urls {
+				u := "https://example.com/" + string(rune('a'+i))
+				want = append(want, u)
+				pool.submit(checkResult{ProcessResult: timetraveller.ProcessResult{URL: u, Status: timetraveller.StatusNotFound}}, emit)
+				pool.poll(emit)
+			}
+			pool.drain(emit)
+
+			slices.Sort(got)
+			if !slices.Equal(got, want) {
+				t.Errorf("emitted %q, want %q", got, want)
+			}
+			if n := peak.Load(); n > int64(tt.workers) {
+				t.Errorf("%d captures at once, want at most %d", n, tt.workers)
+			}
+		})
+	}
+}
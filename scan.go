@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"regexp"
+)
+
+// scanRule is a named pattern searched for in downloaded snapshot bodies.
+type scanRule struct {
+	name    string
+	pattern *regexp.Regexp
+}
+
+// scanHit is a match of a scanRule inside a snapshot body.
+type scanHit struct {
+	rule   string
+	line   int // 1-based line number
+	offset int // Byte offset of the match within the body
+	match  string
+}
+
+// scanBody returns every match of the rules in body, ordered by rule then offset.
+func scanBody(body []byte, rules []scanRule) []scanHit {
+	var hits []scanHit
+	for _, rule := range rules {
+		line, counted := 1, 0
+		for _, loc := range rule.pattern.FindAllIndex(body, -1) {
+			line += bytes.Count(body[counted:loc[0]], []byte("\n"))
+			counted = loc[0]
+			hits = append(hits, scanHit{
+				rule:   rule.name,
+				line:   line,
+				offset: loc[0],
+				match:  truncate(string(body[loc[0]:loc[1]]), 200),
+			})
+		}
+	}
+	return hits
+}
+
+// truncate shortens s to at most n bytes, marking the cut with "...".
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
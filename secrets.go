@@ -0,0 +1,22 @@
+package main
+
+import "regexp"
+
+// secretRules are the patterns used by -secrets to flag credentials leaked in
+// archived content. They favour well-known token formats over generic entropy
+// checks to keep false positives low.
+var secretRules = []scanRule{
+	{"AWS Access Key ID", regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{"AWS Secret Access Key", regexp.MustCompile(`(?i)aws.{0,20}?(?:secret|key).{0,20}?['"][0-9a-zA-Z/+]{40}['"]`)},
+	{"Google API Key", regexp.MustCompile(`\bAIza[0-9A-Za-z_\-]{35}\b`)},
+	{"GitHub Token", regexp.MustCompile(`\b(?:gh[pousr]_[0-9A-Za-z]{36}|github_pat_[0-9A-Za-z_]{82})\b`)},
+	{"Slack Token", regexp.MustCompile(`\bxox[baprs]-[0-9A-Za-z-]{10,}\b`)},
+	{"Slack Webhook", regexp.MustCompile(`https://hooks\.slack\.com/services/T[0-9A-Za-z]+/B[0-9A-Za-z]+/[0-9A-Za-z]+`)},
+	{"Stripe Secret Key", regexp.MustCompile(`\b[rs]k_live_[0-9A-Za-z]{24,}\b`)},
+	{"Twilio API Key", regexp.MustCompile(`\bSK[0-9a-fA-F]{32}\b`)},
+	{"SendGrid API Key", regexp.MustCompile(`\bSG\.[0-9A-Za-z_\-]{22}\.[0-9A-Za-z_\-]{43}\b`)},
+	{"Mailgun API Key", regexp.MustCompile(`\bkey-[0-9a-zA-Z]{32}\b`)},
+	{"Private Key", regexp.MustCompile(`-----BEGIN (?:RSA |EC |DSA |OPENSSH |PGP )?PRIVATE KEY( BLOCK)?-----`)},
+	{"JSON Web Token", regexp.MustCompile(`\beyJ[0-9A-Za-z_\-]{10,}\.eyJ[0-9A-Za-z_\-]{10,}\.[0-9A-Za-z_\-]{10,}\b`)},
+	{"Generic Credential", regexp.MustCompile(`(?i)\b(?:api[_-]?key|api[_-]?secret|access[_-]?token|auth[_-]?token|client[_-]?secret|password|passwd)\b\s*[:=]\s*['"][^'"\s]{8,}['"]`)},
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aleister1102/timetraveller/pkg/lookuppb"
+	"github.com/aleister1102/timetraveller/pkg/timetraveller"
+	"google.golang.org/grpc"
+)
+
+// maxRequestBody bounds the size of a POST /lookup body.
+const maxRequestBody = 10 << 20
+
+// runServe serves the lookup engine over an HTTP API: POST /lookup starts a
+// job looking up one URL or a batch, and GET /jobs/{id} reports its progress
+// and results. With -grpc-listen, it also serves the gRPC Lookup service.
+func runServe(args []string) {
+	var f engineFlags
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	f.registerWorkers(fs)
+	f.registerLookup(fs)
+	f.registerOutput(fs)
+	listen := fs.String("listen", ":8080", "Address to serve the HTTP API on (\"\" to serve only gRPC)")
+	grpcListen := fs.String("grpc-listen", "", "Address to serve the gRPC Lookup service on, e.g. :9090 (see pkg/lookuppb/lookup.proto)")
+	jobTTL := fs.Duration("job-ttl", time.Hour, "How long finished jobs and their results are kept")
+	maxURLs := fs.Int("max-urls", 10000, "Most URLs accepted in one lookup request")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: timetraveller serve [options]\n")
+		fmt.Fprintf(os.Stderr, "Endpoints:\n")
+		fmt.Fprintf(os.Stderr, "  POST /lookup     {\"urls\": [...]} or {\"url\": \"...\"}, optional \"latest\" and \"all\"; returns a job ID\n")
+		fmt.Fprintf(os.Stderr, "  GET  /jobs/{id}  Progress and results of a job\n")
+		fmt.Fprintf(os.Stderr, "  GET  /metrics    Prometheus metrics\n")
+		fmt.Fprintf(os.Stderr, "gRPC (-grpc-listen): timetraveller.v1.Lookup/Lookup streams URLs in and results out\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	parseFlags(fs, args)
+	if fs.NArg() > 0 || (*listen == "" && *grpcListen == "") {
+		fs.Usage()
+		os.Exit(exitUsage)
+	}
+	opts, err := f.lookupOptions()
+	if err != nil {
+		usageFatalf("%v", err)
+	}
+
+	client := f.client()
+	if f.metrics == nil {
+		f.metrics = newMetrics(client)
+	}
+	s := &lookupServer{f: &f, opts: opts, maxURLs: *maxURLs, jobs: make(map[string]*lookupJob)}
+	go s.expireJobs(*jobTTL)
+
+	var server *http.Server
+	if *listen != "" {
+		listener, err := net.Listen("tcp", *listen)
+		if err != nil {
+			fatalf("Error listening: %v", err)
+		}
+		server = &http.Server{Handler: s.handler()}
+		go server.Serve(listener)
+		slog.Info("Serving API", "url", "http://"+listener.Addr().String())
+	}
+	var grpcServer *grpc.Server
+	if *grpcListen != "" {
+		listener, err := net.Listen("tcp", *grpcListen)
+		if err != nil {
+			fatalf("Error listening: %v", err)
+		}
+		grpcServer = grpc.NewServer()
+		lookuppb.RegisterLookupServer(grpcServer, &lookupService{f: &f, opts: opts})
+		go grpcServer.Serve(listener)
+		slog.Info("Serving gRPC", "address", listener.Addr().String())
+	}
+
+	// Stop taking requests on interrupt, giving running lookups the drain
+	// timeout.
+	stop := f.shutdown()
+	<-stop.dispatch.Done()
+	if grpcServer != nil {
+		go func() {
+			<-stop.requests.Done()
+			grpcServer.Stop()
+		}()
+		grpcServer.GracefulStop()
+	}
+	if server != nil {
+		server.Shutdown(stop.requests)
+	}
+}
+
+// lookupServer runs lookup jobs for the API of "serve".
+type lookupServer struct {
+	f       *engineFlags
+	opts    timetraveller.Options
+	maxURLs int
+
+	mu   sync.Mutex
+	jobs map[string]*lookupJob // By ID
+}
+
+// lookupJob is a batch of lookups started by POST /lookup.
+type lookupJob struct {
+	id       string
+	total    int
+	all      bool
+	mu       sync.Mutex
+	results  []jsonResult
+	finished time.Time // Zero while running
+}
+
+// lookupRequest is the body of POST /lookup.
+type lookupRequest struct {
+	URL    string   `json:"url"`
+	URLs   []string `json:"urls"`
+	Latest bool     `json:"latest"` // Pick the latest snapshot instead of the oldest
+	All    bool     `json:"all"`    // List every snapshot of each URL
+}
+
+// jobStatus is the JSON representation of a job.
+type jobStatus struct {
+	ID        string       `json:"id"`
+	Status    string       `json:"status"` // "running" or "done"
+	Total     int          `json:"total"`
+	Completed int          `json:"completed"`
+	Results   []jsonResult `json:"results"`
+}
+
+func (s *lookupServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /lookup", s.startJob)
+	mux.HandleFunc("GET /jobs/{id}", s.getJob)
+	mux.HandleFunc("GET /metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		s.f.metrics.write(w)
+	})
+	return mux
+}
+
+// startJob handles POST /lookup: it validates the URLs, starts looking them up
+// and answers 202 Accepted with the job.
+func (s *lookupServer) startJob(w http.ResponseWriter, r *http.Request) {
+	var req lookupRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBody)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+		return
+	}
+	urls := req.URLs
+	if req.URL != "" {
+		urls = append(urls, req.URL)
+	}
+	switch {
+	case len(urls) == 0:
+		writeError(w, http.StatusBadRequest, `no URLs given: expected "url" or "urls"`)
+		return
+	case len(urls) > s.maxURLs:
+		writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("%d URLs given, at most %d accepted", len(urls), s.maxURLs))
+		return
+	}
+	for _, u := range urls {
+		if err := validateInputURL(u); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid URL %q: %v", u, err))
+			return
+		}
+	}
+	if s.f.shutdown().dispatch.Err() != nil {
+		writeError(w, http.StatusServiceUnavailable, "shutting down")
+		return
+	}
+
+	prepared, _ := s.f.prepareInput(sendURLs(urls))
+	urls = urls[:0]
+	for u := range prepared {
+		urls = append(urls, u)
+	}
+	job := &lookupJob{id: newJobID(), total: len(urls), all: req.All}
+	s.mu.Lock()
+	s.jobs[job.id] = job
+	s.mu.Unlock()
+
+	opts := s.opts
+	opts.Latest = req.Latest
+	go job.run(startLookups(s.f, sendURLs(urls), opts))
+
+	w.Header().Set("Location", "/jobs/"+job.id)
+	writeJSON(w, http.StatusAccepted, job.status(false))
+}
+
+// getJob handles GET /jobs/{id}.
+func (s *lookupServer) getJob(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	job := s.jobs[r.PathValue("id")]
+	s.mu.Unlock()
+	if job == nil {
+		writeError(w, http.StatusNotFound, "no such job")
+		return
+	}
+	writeJSON(w, http.StatusOK, job.status(true))
+}
+
+// expireJobs forgets the jobs finished more than ttl ago, checking every
+// minute.
+func (s *lookupServer) expireJobs(ttl time.Duration) {
+	for range time.Tick(time.Minute) {
+		s.mu.Lock()
+		for id, job := range s.jobs {
+			job.mu.Lock()
+			if !job.finished.IsZero() && time.Since(job.finished) > ttl {
+				delete(s.jobs, id)
+			}
+			job.mu.Unlock()
+		}
+		s.mu.Unlock()
+	}
+}
+
+// run collects the results of the job until they end.
+func (j *lookupJob) run(results <-chan timetraveller.ProcessResult) {
+	for result := range results {
+		j.mu.Lock()
+		j.results = append(j.results, newJSONResult(result, j.all, false))
+		j.mu.Unlock()
+	}
+	j.mu.Lock()
+	j.finished = time.Now()
+	j.mu.Unlock()
+}
+
+// status returns the state of the job, with the results so far if asked.
+func (j *lookupJob) status(withResults bool) jobStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	status := jobStatus{ID: j.id, Status: "running", Total: j.total, Completed: len(j.results), Results: []jsonResult{}}
+	if !j.finished.IsZero() {
+		status.Status = "done"
+	}
+	if withResults {
+		status.Results = append(status.Results, j.results...)
+	}
+	return status
+}
+
+// newJobID returns a random job ID.
+func newJobID() string {
+	id := make([]byte, 8)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// writeJSON answers with v as JSON.
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError answers with a JSON error message.
+func writeError(w http.ResponseWriter, code int, message string) {
+	writeJSON(w, code, map[string]string{"error": message})
+}
@@ -0,0 +1,493 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/aleister1102/timetraveller/pkg/timetraveller"
+)
+
+// sinkKinds lists the destinations -sink accepts, in the order of its help.
+var sinkKinds = []string{"stdout", "file", "jsonl", "csv", "sqlite", "webhook", "elasticsearch"}
+
+// checkResult is a lookup result as "check" hands it to its sinks, with what
+// the run added to it.
+type checkResult struct {
+	timetraveller.ProcessResult
+	SavedURL string // Capture created by -save-missing
+	SaveErr  error  // Why -save-missing failed
+	Change   string // How the result differs from the -diff-run run
+	Expected string // Archive URL expected by -verify-map
+	Verify   bool   // Set if -verify-map lists the URL
+}
+
+// matches reports whether the result found the archive URL -verify-map expects.
+func (r checkResult) matches() bool {
+	return r.Status == timetraveller.StatusFound && sameArchiveURL(r.OldestURL, r.Expected)
+}
+
+// json returns the JSON representation of the result.
+func (r checkResult) json(all, changes bool) jsonResult {
+	out := newJSONResult(r.ProcessResult, all, changes)
+	out.SavedURL = r.SavedURL
+	out.Change = r.Change
+	if r.SaveErr != nil {
+		out.SaveError = r.SaveErr.Error()
+	}
+	if r.Verify {
+		match := r.matches()
+		out.ExpectedArchiveURL, out.Match = r.Expected, &match
+	}
+	return out
+}
+
+// sink is a destination for the results of "check". Flush pushes out what was
+// written so far, so a -resume checkpoint never gets ahead of the output, and
+// Close ends the output once the run is done.
+type sink interface {
+	Write(r checkResult) error
+	Flush() error
+	Close() error
+}
+
+// sinkStack writes every result to each of its sinks in turn. Errors name the
+// sink they come from.
+type sinkStack []stackedSink
+
+type stackedSink struct {
+	name string
+	sink
+}
+
+func (s *sinkStack) add(name string, snk sink) {
+	*s = append(*s, stackedSink{name, snk})
+}
+
+func (s sinkStack) Write(r checkResult) error {
+	for _, snk := range s {
+		if err := snk.Write(r); err != nil {
+			return fmt.Errorf("%s: %w", snk.name, err)
+		}
+	}
+	return nil
+}
+
+func (s sinkStack) Flush() error {
+	for _, snk := range s {
+		if err := snk.Flush(); err != nil {
+			return fmt.Errorf("%s: %w", snk.name, err)
+		}
+	}
+	return nil
+}
+
+// Close closes every sink, returning the first error.
+func (s sinkStack) Close() error {
+	var first error
+	for _, snk := range s {
+		if err := snk.Close(); err != nil && first == nil {
+			first = fmt.Errorf("%s: %w", snk.name, err)
+		}
+	}
+	return first
+}
+
+// sinkSpec is one destination given with -sink as kind[:target].
+type sinkSpec struct {
+	kind   string
+	target string
+}
+
+// sinkFlag collects the values of the repeatable -sink flag.
+type sinkFlag []sinkSpec
+
+func (s *sinkFlag) String() string {
+	specs := make([]string, len(*s))
+	for i, spec := range *s {
+		specs[i] = spec.kind
+		if spec.target != "" {
+			specs[i] += ":" + spec.target
+		}
+	}
+	return strings.Join(specs, ",")
+}
+
+func (s *sinkFlag) Set(value string) error {
+	kind, target, _ := strings.Cut(value, ":")
+	switch kind {
+	case "stdout":
+		if target != "" {
+			return fmt.Errorf("stdout takes no target")
+		}
+	case "file", "jsonl", "csv", "sqlite":
+		if target == "" {
+			return fmt.Errorf("%s needs a path, e.g. %s:results.txt", kind, kind)
+		}
+	case "webhook":
+		if err := validateWebhookURL(target); err != nil {
+			return fmt.Errorf("invalid webhook URL %q: %w", target, err)
+		}
+	case "elasticsearch":
+		if err := validateESURL(target); err != nil {
+			return fmt.Errorf("invalid Elasticsearch URL %q: %w", target, err)
+		}
+	default:
+		return fmt.Errorf("unknown sink %q: expected one of %s", kind, strings.Join(sinkKinds, ", "))
+	}
+	*s = append(*s, sinkSpec{kind, target})
+	return nil
+}
+
+// has reports whether a sink of the kind was given.
+func (s sinkFlag) has(kind string) bool {
+	for _, spec := range s {
+		if spec.kind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// openSinks opens the destinations given with -o, -csv, -db, -webhook and
+// -sink, except stdout. Database sinks go to records, which gets every result
+// so later -diff-run runs compare with the whole run; the rest go to outputs,
+// which gets the results left after filtering. With appendTo set, URL, JSONL
+// and CSV files are appended to instead of replaced.
+func (f *checkFlags) openSinks(opts timetraveller.Options, appendTo bool) (records, outputs sinkStack, err error) {
+	specs := f.sinks
+	if f.outputFile != "" {
+		specs = append(sinkFlag{{"file", f.outputFile}}, specs...)
+	}
+	if f.csvFile != "" {
+		specs = append(specs, sinkSpec{"csv", f.csvFile})
+	}
+	if f.dbFile != "" {
+		specs = append(specs, sinkSpec{"sqlite", f.dbFile})
+	}
+	defer func() {
+		if err != nil {
+			records.Close()
+			outputs.Close()
+		}
+	}()
+	for _, spec := range specs {
+		switch spec.kind {
+		case "file":
+			file, err := createURLFile(spec.target, f.gzipLevel, appendTo)
+			if err != nil {
+				return records, outputs, fmt.Errorf("creating output file: %w", err)
+			}
+			outputs.add("output file", &urlSink{file: file, path: spec.target, all: f.allSnapshots, changes: f.changes})
+		case "jsonl":
+			file, err := createURLFile(spec.target, f.gzipLevel, appendTo)
+			if err != nil {
+				return records, outputs, fmt.Errorf("creating JSONL file: %w", err)
+			}
+			outputs.add("JSONL file", &jsonlSink{file: file, all: f.allSnapshots, changes: f.changes})
+		case "csv":
+			snk, err := newCSVSink(spec.target, appendTo, f.verifyMap != "")
+			if err != nil {
+				return records, outputs, fmt.Errorf("creating CSV file: %w", err)
+			}
+			outputs.add("CSV file", snk)
+		case "sqlite":
+			store, err := openResultStore(spec.target, "check", opts)
+			if err != nil {
+				return records, outputs, fmt.Errorf("opening database: %w", err)
+			}
+			records.add("database", storeSink{store})
+		case "webhook":
+			outputs.add("webhook", f.newWebhookSink(spec.target, false))
+		case "elasticsearch":
+			snk, err := f.newESSink(spec.target)
+			if err != nil {
+				return records, outputs, fmt.Errorf("opening Elasticsearch sink: %w", err)
+			}
+			outputs.add("Elasticsearch", snk)
+		}
+	}
+	if f.webhook != "" {
+		outputs.add("webhook", f.newWebhookSink(f.webhook, true))
+	}
+	return records, outputs, nil
+}
+
+// urlSink writes the archive URLs of found results to a file, one per line.
+type urlSink struct {
+	file         *urlFile
+	path         string
+	all, changes bool
+}
+
+func (s *urlSink) Write(r checkResult) error {
+	if r.Status != timetraveller.StatusFound {
+		return nil
+	}
+	for _, u := range snapshotURLs(r.ProcessResult, s.all, s.changes) {
+		if err := s.file.write(u); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *urlSink) Flush() error { return s.file.flush() }
+func (s *urlSink) Close() error { return s.file.Close() }
+
+// jsonlSink writes every result to a file as one JSON object per line.
+type jsonlSink struct {
+	file         *urlFile
+	all, changes bool
+}
+
+func (s *jsonlSink) Write(r checkResult) error {
+	data, err := json.Marshal(r.json(s.all, s.changes))
+	if err != nil {
+		return err
+	}
+	return s.file.write(string(data))
+}
+
+func (s *jsonlSink) Flush() error { return s.file.flush() }
+func (s *jsonlSink) Close() error { return s.file.Close() }
+
+// csvSink writes the CDX fields of every snapshot to a CSV file, followed by
+// the -verify-map comparison if verify is set.
+type csvSink struct {
+	file   *os.File
+	w      *csv.Writer
+	verify bool
+}
+
+// newCSVSink creates the CSV file at path, or with appendTo set appends to it,
+// writing the header only to an empty file.
+func newCSVSink(path string, appendTo, verify bool) (*csvSink, error) {
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if appendTo {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+	file, err := os.OpenFile(path, flags, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	s := &csvSink{file: file, w: csv.NewWriter(file), verify: verify}
+	if info.Size() > 0 {
+		return s, nil
+	}
+	header := snapshotCSVHeader
+	if verify {
+		header = slices.Concat(snapshotCSVHeader, verifyCSVHeader)
+	}
+	if err := s.w.Write(header); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *csvSink) Write(r checkResult) error {
+	if !s.verify {
+		return writeSnapshotCSV(s.w, r.ProcessResult)
+	}
+	if !r.Verify {
+		return writeSnapshotCSV(s.w, r.ProcessResult, "", "")
+	}
+	return writeSnapshotCSV(s.w, r.ProcessResult, r.Expected, strconv.FormatBool(r.matches()))
+}
+
+func (s *csvSink) Flush() error {
+	s.w.Flush()
+	return s.w.Error()
+}
+
+func (s *csvSink) Close() error {
+	err := s.Flush()
+	if closeErr := s.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// storeSink records results in a -db database.
+type storeSink struct {
+	store *resultStore
+}
+
+func (s storeSink) Write(r checkResult) error { return s.store.add(r.ProcessResult) }
+func (s storeSink) Flush() error              { return nil }
+func (s storeSink) Close() error              { return s.store.Close() }
+
+// printSink prints results on stdout: as colored text, or as -json, -jsonl,
+// -format or -silent ask.
+type printSink struct {
+	f           *checkFlags
+	w           io.Writer
+	ui          *dashboard
+	format      *template.Template
+	label       string // Names the chosen snapshot in text lines
+	checkpoints []time.Time
+	atWindow    time.Duration
+	jsonArray   *jsonArrayWriter
+	jsonl       *json.Encoder
+}
+
+func newPrintSink(f *checkFlags, w io.Writer, ui *dashboard, format *template.Template,
+	checkpoints []time.Time, atWindow time.Duration) *printSink {
+	label := "Oldest:"
+	if f.latestSnapshot {
+		label = "Latest:"
+	} else if f.closest != "" {
+		label = "Closest:"
+	}
+	return &printSink{f: f, w: w, ui: ui, format: format, label: label, checkpoints: checkpoints,
+		atWindow: atWindow, jsonArray: &jsonArrayWriter{w: w}, jsonl: json.NewEncoder(w)}
+}
+
+func (s *printSink) Write(r checkResult) error {
+	f := s.f
+	if f.unicode {
+		r.URL = timetraveller.UnicodeURL(r.URL)
+	}
+
+	if f.silent {
+		if r.Status == timetraveller.StatusFound {
+			for _, u := range snapshotURLs(r.ProcessResult, f.allSnapshots, f.changes) {
+				if _, err := fmt.Fprintln(s.w, u); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	if s.format != nil {
+		if err := s.format.Execute(s.w, r.ProcessResult); err != nil {
+			return fmt.Errorf("executing -format template: %w", err)
+		}
+		_, err := fmt.Fprintln(s.w)
+		return err
+	}
+
+	if f.jsonlOutput {
+		return s.jsonl.Encode(r.json(f.allSnapshots, f.changes))
+	}
+	if f.jsonOutput {
+		return s.jsonArray.write(r.json(f.allSnapshots, f.changes))
+	}
+
+	outputLine := s.line(r)
+	if s.ui != nil {
+		s.ui.print(outputLine)
+	}
+	_, err := fmt.Fprintln(s.w, outputLine)
+	return err
+}
+
+// line returns the colored text line of a result, compared with the archive
+// URL expected by -verify-map if it lists the URL.
+func (s *printSink) line(r checkResult) string {
+	f := s.f
+	var outputLine string
+	if r.Error != nil {
+		// The error is a result line; -log-file keeps it too.
+		fileLog(slog.LevelError, "Lookup failed", "url", r.URL, "error", r.Error)
+		outputLine = fmt.Sprintf(ColorRed+"[!] %s - %v"+ColorReset,
+			r.URL, r.Error)
+	} else {
+		switch r.Status {
+		case timetraveller.StatusFound:
+			if f.countOnly {
+				outputLine = fmt.Sprintf(ColorGreen+"[+] %s - Snapshots: %d"+ColorReset,
+					r.URL, r.SnapshotCount)
+				break
+			}
+			if f.fast {
+				// The availability API does not count captures.
+				outputLine = fmt.Sprintf(ColorGreen+"[+] %s - %s %s"+ColorReset, r.URL, s.label, r.OldestURL)
+			} else {
+				outputLine = fmt.Sprintf(ColorGreen+"[+] %s - Snapshots: %d - %s %s"+ColorReset,
+					r.URL, r.SnapshotCount, s.label, r.OldestURL)
+			}
+			if r.DetailsURL != "" {
+				outputLine += fmt.Sprintf(ColorGreen+" - Details: %s"+ColorReset, r.DetailsURL)
+			}
+			if len(s.checkpoints) > 0 {
+				outputLine += fmt.Sprintf(ColorGreen+" - At: %s"+ColorReset,
+					formatAvailability(f.atDates, availabilityMatrix(r.Snapshots, s.checkpoints, s.atWindow)))
+			}
+			if f.allSnapshots {
+				for _, entry := range r.Snapshots {
+					outputLine += fmt.Sprintf("\n    %s %s", entry.Field(timetraveller.FieldTimestamp), entry.ArchiveURL())
+					if entry.Field(timetraveller.FieldMementoURL) != "" {
+						outputLine += " (" + entry.Archive() + ")"
+					}
+				}
+			}
+			if f.changes {
+				changes := r.Changes()
+				outputLine += fmt.Sprintf(ColorGreen+" - Changes: %d"+ColorReset, len(changes))
+				for _, entry := range changes {
+					outputLine += fmt.Sprintf("\n    %s %s %s", entry.Field(timetraveller.FieldTimestamp),
+						entry.Field(timetraveller.FieldDigest), entry.ArchiveURL())
+				}
+			}
+		case timetraveller.StatusNotFound:
+			outputLine = fmt.Sprintf(ColorYellow+"[-] %s"+ColorReset,
+				r.URL)
+			if len(s.checkpoints) > 0 {
+				outputLine += fmt.Sprintf(ColorYellow+" - At: %s"+ColorReset,
+					formatAvailability(f.atDates, make([]bool, len(s.checkpoints))))
+			}
+			if r.SavedURL != "" {
+				outputLine += fmt.Sprintf(ColorGreen+" - Saved: %s"+ColorReset, r.SavedURL)
+			} else if r.SaveErr != nil {
+				outputLine += fmt.Sprintf(ColorRed+" - Save failed: %v"+ColorReset, r.SaveErr)
+			}
+		default:
+			outputLine = fmt.Sprintf(ColorCyan+"[i] %s - Status: %s (Unknown)"+ColorReset,
+				r.URL, r.Status)
+		}
+
+		if r.Verify {
+			if r.matches() {
+				outputLine = fmt.Sprintf(ColorGreen+"[=] %s - Match: %s"+ColorReset,
+					r.URL, r.OldestURL)
+			} else {
+				got := r.OldestURL
+				if got == "" {
+					got = r.Status
+				}
+				outputLine = fmt.Sprintf(ColorRed+"[x] %s - Mismatch: expected %s, got %s"+ColorReset,
+					r.URL, r.Expected, got)
+			}
+		}
+	}
+	if r.Change != "" {
+		outputLine += fmt.Sprintf(ColorCyan+" - Change: %s"+ColorReset, r.Change)
+	}
+	return outputLine
+}
+
+func (s *printSink) Flush() error { return nil }
+
+// Close ends the -json array.
+func (s *printSink) Close() error {
+	if s.f.jsonOutput {
+		return s.jsonArray.close()
+	}
+	return nil
+}
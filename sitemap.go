@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+)
+
+// sitemapLocations returns the <loc> URLs of a sitemap or sitemap index,
+// including the URLs of nested sitemaps an index points to.
+func sitemapLocations(body []byte) []string {
+	var locations []string
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	decoder.Strict = false
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			// Archived sitemaps are often truncated; keep what parsed.
+			return locations
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "loc" {
+			continue
+		}
+		var loc string
+		if err := decoder.DecodeElement(&loc, &start); err != nil {
+			return locations
+		}
+		if loc = strings.TrimSpace(loc); loc != "" {
+			locations = append(locations, loc)
+		}
+	}
+}
+
+// runSitemap prints every URL listed by the archived versions of each host's
+// sitemap.xml.
+func runSitemap(args []string) {
+	runWellKnown(wellKnownFile{
+		command: "sitemap",
+		path:    "/sitemap.xml",
+		entries: "listed URLs",
+		maxSize: 10 << 20,
+		extract: sitemapLocations,
+	}, args)
+}
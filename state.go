@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// runState maps each input URL, as prepared for lookup, to the timestamp of
+// its latest known capture.
+// It is persisted between runs with -state.
+type runState map[string]string
+
+// loadState reads a state file. A missing file yields an empty state.
+func loadState(filename string) (runState, error) {
+	state := make(runState)
+	data, err := os.ReadFile(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// saveState writes the state to a temporary file and renames it into place,
+// so an interrupted run never leaves a truncated state file behind.
+func saveState(filename string, state runState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filename)
+}
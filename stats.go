@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aleister1102/timetraveller/pkg/timetraveller"
+)
+
+// runStats tallies the results of a run for its summary.
+type runStats struct {
+	client    *timetraveller.Client
+	requests  timetraveller.Stats // Client counts when the run started
+	start     time.Time
+	processed int
+	found     int
+	notFound  int
+	failed    int
+	snapshots int
+	errors    map[string]int // By timetraveller.ErrorKind
+}
+
+// newRunStats starts tallying a run of lookups by client.
+func newRunStats(client *timetraveller.Client) *runStats {
+	return &runStats{
+		client:   client,
+		requests: client.Stats(),
+		start:    time.Now(),
+		errors:   make(map[string]int),
+	}
+}
+
+// add counts a result.
+func (s *runStats) add(result timetraveller.ProcessResult) {
+	s.processed++
+	switch {
+	case result.Error != nil:
+		s.failed++
+		s.errors[timetraveller.ErrorKind(result.Error)]++
+	case result.Status == timetraveller.StatusFound:
+		s.found++
+		s.snapshots += result.SnapshotCount
+	default:
+		s.notFound++
+	}
+}
+
+// runSummary is the -stats-json form of a run's summary.
+type runSummary struct {
+	Processed         int            `json:"processed"`
+	Found             int            `json:"found"`
+	NotFound          int            `json:"not_found"`
+	Errors            int            `json:"errors"`
+	Snapshots         int            `json:"snapshots"`
+	ErrorKinds        map[string]int `json:"error_kinds"`
+	Requests          int64          `json:"requests"`
+	Retries           int64          `json:"retries"`
+	RateLimited       int64          `json:"rate_limited"`
+	ElapsedSeconds    float64        `json:"elapsed_seconds"`
+	RequestsPerSecond float64        `json:"requests_per_second"`
+}
+
+// summary returns the totals of the run so far.
+func (s *runStats) summary() runSummary {
+	elapsed := time.Since(s.start)
+	now := s.client.Stats()
+	requests := now.Requests - s.requests.Requests
+	return runSummary{
+		Processed:         s.processed,
+		Found:             s.found,
+		NotFound:          s.notFound,
+		Errors:            s.failed,
+		Snapshots:         s.snapshots,
+		ErrorKinds:        s.errors,
+		Requests:          requests,
+		Retries:           now.Retries - s.requests.Retries,
+		RateLimited:       now.RateLimited - s.requests.RateLimited,
+		ElapsedSeconds:    elapsed.Seconds(),
+		RequestsPerSecond: float64(requests) / elapsed.Seconds(),
+	}
+}
+
+// report prints the summary of the run on stderr, as one JSON object if
+// asJSON is set.
+func (s *runStats) report(asJSON bool) {
+	summary := s.summary()
+	if asJSON {
+		// Errors stay visible with -silent, and so does the summary asked for.
+		data, err := json.Marshal(summary)
+		if err != nil {
+			return
+		}
+		consoleMu.Lock()
+		defer consoleMu.Unlock()
+		fmt.Fprintf(consoleOut, "%s\n", data)
+		return
+	}
+
+	kinds := slices.Sorted(maps.Keys(summary.ErrorKinds))
+	breakdown := make([]string, len(kinds))
+	for i, kind := range kinds {
+		breakdown[i] = fmt.Sprintf("%s:%d", kind, summary.ErrorKinds[kind])
+	}
+	slog.Info("Run summary",
+		"processed", summary.Processed,
+		"found", summary.Found,
+		"not_found", summary.NotFound,
+		"errors", summary.Errors,
+		"error_kinds", strings.Join(breakdown, ","),
+		"snapshots", summary.Snapshots,
+		"requests", summary.Requests,
+		"retries", summary.Retries,
+		"rate_limited", summary.RateLimited,
+		"elapsed", time.Duration(summary.ElapsedSeconds*float64(time.Second)).Round(time.Millisecond),
+		"requests_per_second", fmt.Sprintf("%.1f", summary.RequestsPerSecond))
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/aleister1102/timetraveller/pkg/timetraveller"
+	_ "modernc.org/sqlite"
+)
+
+// storeSchema creates the tables of a -db database. Every run gets a row in
+// runs; results and snapshots refer to it, so runs can be compared later.
+const storeSchema = `
+CREATE TABLE IF NOT EXISTS runs (
+	id          INTEGER PRIMARY KEY,
+	command     TEXT NOT NULL,
+	options     TEXT NOT NULL,
+	started_at  TEXT NOT NULL,
+	finished_at TEXT
+);
+CREATE TABLE IF NOT EXISTS results (
+	run_id         INTEGER NOT NULL REFERENCES runs(id),
+	url            TEXT NOT NULL,
+	status         TEXT NOT NULL,
+	snapshot_count INTEGER NOT NULL,
+	snapshot_url   TEXT,
+	error          TEXT,
+	PRIMARY KEY (run_id, url)
+);
+CREATE TABLE IF NOT EXISTS snapshots (
+	run_id      INTEGER NOT NULL REFERENCES runs(id),
+	url         TEXT NOT NULL,
+	timestamp   TEXT NOT NULL,
+	original    TEXT,
+	mimetype    TEXT,
+	statuscode  TEXT,
+	digest      TEXT,
+	length      TEXT,
+	archive_url TEXT
+);
+CREATE INDEX IF NOT EXISTS snapshots_run_url ON snapshots (run_id, url);
+`
+
+// resultStore records the results of one run in a SQLite database.
+type resultStore struct {
+	db    *sql.DB
+	runID int64
+}
+
+// openResultStore opens (creating if needed) the database at path and starts
+// a run of command with the given lookup options.
+func openResultStore(path, command string, opts timetraveller.Options) (*resultStore, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	// Results are written by one goroutine; a single connection avoids
+	// SQLITE_BUSY between pooled connections.
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec("PRAGMA journal_mode = WAL; PRAGMA synchronous = NORMAL;" + storeSchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	// Credentials stay out of the database.
+	opts.ArchiveItAuth = ""
+	options, err := json.Marshal(opts)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	res, err := db.Exec("INSERT INTO runs (command, options, started_at) VALUES (?, ?, ?)",
+		command, string(options), time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	s := &resultStore{db: db}
+	if s.runID, err = res.LastInsertId(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// add records a result and its snapshots.
+func (s *resultStore) add(r timetraveller.ProcessResult) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var errText sql.NullString
+	if r.Error != nil {
+		errText = sql.NullString{String: r.Error.Error(), Valid: true}
+	}
+	if _, err := tx.Exec("INSERT OR REPLACE INTO results (run_id, url, status, snapshot_count, snapshot_url, error) VALUES (?, ?, ?, ?, ?, ?)",
+		s.runID, r.URL, r.Status, r.SnapshotCount, r.OldestURL, errText); err != nil {
+		return err
+	}
+	for _, entry := range r.Snapshots {
+		if _, err := tx.Exec("INSERT INTO snapshots (run_id, url, timestamp, original, mimetype, statuscode, digest, length, archive_url) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			s.runID, r.URL,
+			entry.Field(timetraveller.FieldTimestamp),
+			entry.Field(timetraveller.FieldOriginal),
+			entry.Field(timetraveller.FieldMimetype),
+			entry.Field(timetraveller.FieldStatusCode),
+			entry.Field(timetraveller.FieldDigest),
+			entry.Field(timetraveller.FieldLength),
+			entry.ArchiveURL()); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Close marks the run finished and closes the database.
+func (s *resultStore) Close() error {
+	_, err := s.db.Exec("UPDATE runs SET finished_at = ? WHERE id = ?", time.Now().UTC().Format(time.RFC3339), s.runID)
+	if closeErr := s.db.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
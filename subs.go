@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/aleister1102/timetraveller/pkg/timetraveller"
+)
+
+// runSubs prints every unique hostname archived under the input domains.
+func runSubs(args []string) {
+	var f engineFlags
+	fs := flag.NewFlagSet("subs", flag.ExitOnError)
+	f.register(fs)
+	outputFile := fs.String("o", "", "File to write discovered subdomains to")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: timetraveller subs [options] <domain1> [domain2 ...]\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	parseFlags(fs, args)
+
+	domains, inputStats, err := f.readInput(fs.Args())
+	if err != nil {
+		usageFatalf("%v", err)
+	}
+	inputStats.report()
+	if len(domains) == 0 {
+		fs.Usage()
+		os.Exit(exitUsage)
+	}
+
+	opts, err := f.lookupOptions()
+	if err != nil {
+		usageFatalf("%v", err)
+	}
+	opts.MatchType = timetraveller.MatchDomain
+
+	seen := make(map[string]bool)
+	var subdomains []string
+	harvestOriginals(&f, domains, opts, func(_, original string) {
+		host := hostOf(original)
+		if host == "" || seen[host] {
+			return
+		}
+		seen[host] = true
+		subdomains = append(subdomains, host)
+		fmt.Println(host)
+	})
+
+	if *outputFile != "" && len(subdomains) > 0 {
+		if err := writeUrlsToFile(*outputFile, subdomains, f.gzipLevel); err != nil {
+			fatalf("Error writing to output file: %v", err)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// defaultUserAgent identifies the tool to the archives it queries.
+const defaultUserAgent = "timetraveller (+https://github.com/aleister1102/timetraveller)"
+
+// headerTransport sets the User-Agent of every request, rotating through
+// userAgents in turn, and the -H headers, which replace any the request
+// already has, before passing it on to base.
+type headerTransport struct {
+	base       http.RoundTripper
+	userAgents []string
+	headers    http.Header
+	next       atomic.Uint64
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// A RoundTripper must not modify the request it is given.
+	req = req.Clone(req.Context())
+	i := (t.next.Add(1) - 1) % uint64(len(t.userAgents))
+	req.Header.Set("User-Agent", t.userAgents[i])
+	for name, values := range t.headers {
+		req.Header[name] = values
+	}
+	return t.base.RoundTrip(req)
+}
+
+// loadUserAgents reads the User-Agent strings listed one per line in
+// filename, skipping blank lines and # comments.
+func loadUserAgents(filename string) ([]string, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var userAgents []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			userAgents = append(userAgents, line)
+		}
+	}
+	if len(userAgents) == 0 {
+		return nil, fmt.Errorf("no User-Agents listed")
+	}
+	return userAgents, nil
+}
+
+// CloseIdleConnections closes the idle connections of base, so that
+// http.Client.CloseIdleConnections reaches it.
+func (t *headerTransport) CloseIdleConnections() {
+	if closer, ok := t.base.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
+
+// headerFlag collects the "Name: value" headers of the repeatable -H flag.
+type headerFlag http.Header
+
+func (h *headerFlag) String() string {
+	var headers []string
+	for name, values := range *h {
+		for _, value := range values {
+			headers = append(headers, name+": "+value)
+		}
+	}
+	return strings.Join(headers, ", ")
+}
+
+func (h *headerFlag) Set(value string) error {
+	name, val, ok := strings.Cut(value, ":")
+	name = strings.TrimSpace(name)
+	if !ok || name == "" || strings.ContainsAny(name, " \t") {
+		return fmt.Errorf("invalid header %q: expected \"Name: value\"", value)
+	}
+	if *h == nil {
+		*h = make(headerFlag)
+	}
+	http.Header(*h).Add(name, strings.TrimSpace(val))
+	return nil
+}
+
+// tlsVersions maps the -tls-min-version names to TLS versions.
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// tlsConfig returns the TLS settings of the -insecure, -ca-cert and
+// -tls-min-version flags. A CA certificate is trusted on top of the system
+// roots.
+func tlsConfig(insecure bool, caCert, minVersion string) (*tls.Config, error) {
+	config := &tls.Config{InsecureSkipVerify: insecure}
+	if minVersion != "" {
+		version, ok := tlsVersions[minVersion]
+		if !ok {
+			return nil, fmt.Errorf("invalid -tls-min-version %q: expected 1.0, 1.1, 1.2 or 1.3", minVersion)
+		}
+		config.MinVersion = version
+	}
+	if caCert != "" {
+		pem, err := os.ReadFile(caCert)
+		if err != nil {
+			return nil, err
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no PEM certificates found in %s", caCert)
+		}
+		config.RootCAs = pool
+	}
+	return config, nil
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aleister1102/timetraveller/pkg/timetraveller"
+	"golang.org/x/term"
+)
+
+// dashboardResults is how many result lines the dashboard keeps.
+const dashboardResults = 500
+
+// dashboard is the full-screen view of -tui: run counters, the URLs being
+// looked up, rate limiting and the latest results, redrawn on stderr's
+// alternate screen. Keys are read from the terminal in raw mode: p pauses or
+// resumes dispatching and q (or Ctrl-C) stops the run gracefully, a second
+// press aborting in-flight requests as a second SIGINT would.
+type dashboard struct {
+	stop      *shutdown
+	tty       *os.File
+	ttyState  *term.State
+	start     time.Time
+	done      chan struct{}
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+
+	mu          sync.Mutex
+	queued      int
+	inputDone   bool
+	processed   int
+	found       int
+	notFound    int
+	failed      int
+	inFlight    map[string]time.Time // Start time by URL
+	rateLimited int                  // Rate-limited responses in total
+	consecutive int                  // Rate-limited responses in a row, as last reported
+	lastLimited time.Time
+	paused      bool
+	results     []string
+}
+
+// newDashboard takes over the terminal, which stderr must be, until Close.
+func newDashboard(stop *shutdown) (*dashboard, error) {
+	if !isTerminal(os.Stderr) {
+		return nil, fmt.Errorf("-tui needs stderr to be a terminal")
+	}
+	// Keys come from the terminal itself, since stdin may be the input list.
+	tty, err := os.Open("/dev/tty")
+	if err != nil {
+		return nil, fmt.Errorf("opening the terminal for -tui: %w", err)
+	}
+	state, err := term.MakeRaw(int(tty.Fd()))
+	if err != nil {
+		tty.Close()
+		return nil, fmt.Errorf("opening the terminal for -tui: %w", err)
+	}
+	d := &dashboard{
+		stop:     stop,
+		tty:      tty,
+		ttyState: state,
+		start:    time.Now(),
+		done:     make(chan struct{}),
+		inFlight: make(map[string]time.Time),
+	}
+	// Alternate screen, hidden cursor.
+	fmt.Fprint(os.Stderr, "\033[?1049h\033[?25l")
+	log.SetOutput(dashboardLog{d})
+	d.wg.Add(1)
+	go d.redraw()
+	go d.readKeys()
+	return d, nil
+}
+
+// count passes on the URLs of in, counting them as queued.
+func (d *dashboard) count(in <-chan string) <-chan string {
+	out := make(chan string)
+	go func() {
+		defer close(out)
+		for u := range in {
+			d.mu.Lock()
+			d.queued++
+			d.mu.Unlock()
+			out <- u
+		}
+		d.mu.Lock()
+		d.inputDone = true
+		d.mu.Unlock()
+	}()
+	return out
+}
+
+// dispatched records that a worker started looking up u.
+func (d *dashboard) dispatched(u string) {
+	d.mu.Lock()
+	d.inFlight[u] = time.Now()
+	d.mu.Unlock()
+}
+
+// limited records a rate-limited response; it is a Client.OnRateLimited hook.
+func (d *dashboard) limited(consecutive int) {
+	d.mu.Lock()
+	d.rateLimited++
+	d.consecutive = consecutive
+	d.lastLimited = time.Now()
+	d.mu.Unlock()
+}
+
+// add records a result.
+func (d *dashboard) add(result timetraveller.ProcessResult) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.inFlight, result.URL)
+	d.processed++
+	switch {
+	case result.Error != nil:
+		d.failed++
+	case result.Status == timetraveller.StatusFound:
+		d.found++
+	default:
+		d.notFound++
+	}
+}
+
+// print adds the lines of a printed result to the results pane.
+func (d *dashboard) print(output string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.results = append(d.results, strings.Split(output, "\n")...)
+	if len(d.results) > dashboardResults {
+		d.results = slices.Clone(d.results[len(d.results)-dashboardResults:])
+	}
+}
+
+// readKeys handles the key presses until the terminal is closed.
+func (d *dashboard) readKeys() {
+	buf := make([]byte, 16)
+	for {
+		n, err := d.tty.Read(buf)
+		if err != nil {
+			return
+		}
+		for _, key := range buf[:n] {
+			switch key {
+			case 'p', 'P', ' ':
+				d.mu.Lock()
+				d.paused = !d.paused
+				d.stop.setPaused(d.paused)
+				d.mu.Unlock()
+			case 'q', 'Q', 3: // 3 is Ctrl-C, which raw mode delivers as a key
+				d.stop.interrupt()
+			}
+		}
+	}
+}
+
+// redraw draws the dashboard every progressInterval until Close.
+func (d *dashboard) redraw() {
+	defer d.wg.Done()
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+	for {
+		d.draw()
+		select {
+		case <-ticker.C:
+		case <-d.done:
+			return
+		}
+	}
+}
+
+// draw writes one frame. Raw mode turns off the terminal's newline
+// translation, so lines end in "\r\n".
+func (d *dashboard) draw() {
+	width, height, err := term.GetSize(int(os.Stderr.Fd()))
+	if err != nil || width <= 0 || height <= 0 {
+		width, height = 80, 24
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	elapsed := time.Since(d.start)
+	rate := float64(d.processed) / elapsed.Seconds()
+	state := "RUNNING"
+	switch {
+	case d.stop.dispatch.Err() != nil:
+		state = "STOPPING"
+	case d.paused:
+		state = "PAUSED"
+	}
+	total := fmt.Sprintf("%d+", d.queued)
+	eta := "?"
+	if d.inputDone {
+		total = fmt.Sprint(d.queued)
+		if rate > 0 {
+			eta = (time.Duration(float64(d.queued-d.processed)/rate) * time.Second).String()
+		}
+	}
+
+	lines := []string{
+		fmt.Sprintf("timetraveller check - %s - %s elapsed - [p] pause/resume  [q] stop", state, elapsed.Round(time.Second)),
+		fmt.Sprintf("Processed %d/%s | "+ColorGreen+"%d found"+ColorReset+", "+ColorYellow+"%d not found"+ColorReset+", "+ColorRed+"%d errors"+ColorReset+" | %.1f/s | ETA %s",
+			d.processed, total, d.found, d.notFound, d.failed, rate, eta),
+	}
+	if d.rateLimited == 0 {
+		lines = append(lines, "Rate limited: never")
+	} else {
+		lines = append(lines, fmt.Sprintf(ColorRed+"Rate limited: %d times, last %s ago, %d in a row"+ColorReset,
+			d.rateLimited, time.Since(d.lastLimited).Round(time.Second), d.consecutive))
+	}
+
+	// The in-flight lookups take up to a third of the screen, oldest first.
+	urls := make([]string, 0, len(d.inFlight))
+	for u := range d.inFlight {
+		urls = append(urls, u)
+	}
+	slices.SortFunc(urls, func(a, b string) int { return d.inFlight[a].Compare(d.inFlight[b]) })
+	lines = append(lines, "", fmt.Sprintf("In flight (%d):", len(urls)))
+	shown := min(len(urls), max(height/3-1, 1))
+	for _, u := range urls[:shown] {
+		lines = append(lines, fmt.Sprintf("  %6s  %s", time.Since(d.inFlight[u]).Round(100*time.Millisecond), u))
+	}
+
+	lines = append(lines, "", "Results:")
+	room := max(height-len(lines), 0)
+	lines = append(lines, d.results[max(len(d.results)-room, 0):]...)
+
+	var frame strings.Builder
+	frame.WriteString("\033[H")
+	for i, line := range lines[:min(len(lines), height)] {
+		if i > 0 {
+			frame.WriteString("\r\n")
+		}
+		frame.WriteString(truncateANSI(line, width))
+		frame.WriteString(ColorReset + "\033[K")
+	}
+	frame.WriteString("\033[J")
+	fmt.Fprint(os.Stderr, frame.String())
+}
+
+// Close stops redrawing and gives the terminal back.
+func (d *dashboard) Close() {
+	d.restore()
+	bridgeLog()
+}
+
+// restore stops redrawing and gives the terminal back, once.
+func (d *dashboard) restore() {
+	d.closeOnce.Do(func() {
+		close(d.done)
+		d.wg.Wait()
+		d.stop.setPaused(false)
+		term.Restore(int(d.tty.Fd()), d.ttyState)
+		d.tty.Close()
+		fmt.Fprint(os.Stderr, "\033[?25h\033[?1049l")
+	})
+}
+
+// dashboardLog is the log output while a dashboard is open: it gives the
+// terminal back before a message, which is usually fatal, is written.
+type dashboardLog struct {
+	d *dashboard
+}
+
+func (w dashboardLog) Write(p []byte) (int, error) {
+	w.d.restore()
+	slog.Error(strings.TrimSuffix(string(p), "\n"))
+	return len(p), nil
+}
+
+// truncateANSI cuts s to width visible characters, keeping its ANSI escape
+// sequences intact.
+func truncateANSI(s string, width int) string {
+	var b strings.Builder
+	visible := 0
+	inEscape := false
+	for _, r := range s {
+		switch {
+		case inEscape:
+			b.WriteRune(r)
+			inEscape = !(r >= '@' && r <= '~' && r != '[')
+		case r == '\033':
+			b.WriteRune(r)
+			inEscape = true
+		case visible < width:
+			b.WriteRune(r)
+			visible++
+		}
+	}
+	return b.String()
+}
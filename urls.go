@@ -0,0 +1,69 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/aleister1102/timetraveller/pkg/timetraveller"
+)
+
+// runURLs prints every unique archived URL under the input domains.
+func runURLs(args []string) {
+	var f engineFlags
+	fs := flag.NewFlagSet("urls", flag.ExitOnError)
+	f.register(fs)
+	outputFile := fs.String("o", "", "File to write harvested URLs to")
+	params := fs.Bool("params", false, "Print the query parameter names seen in harvested URLs (deduplicated per host) instead of the URLs")
+	noSubs := fs.Bool("no-subs", false, "Only harvest URLs on the given host, not its subdomains")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: timetraveller urls [options] <domain1> [domain2 ...]\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	parseFlags(fs, args)
+
+	domains, inputStats, err := f.readInput(fs.Args())
+	if err != nil {
+		usageFatalf("%v", err)
+	}
+	inputStats.report()
+	if len(domains) == 0 {
+		fs.Usage()
+		os.Exit(exitUsage)
+	}
+
+	opts, err := f.lookupOptions()
+	if err != nil {
+		usageFatalf("%v", err)
+	}
+	opts.MatchType = timetraveller.MatchDomain
+	if *noSubs {
+		opts.MatchType = timetraveller.MatchHost
+	}
+
+	seen := make(map[string]bool)
+	var harvested []string
+	harvestOriginals(&f, domains, opts, func(_, original string) {
+		items := []string{original}
+		keyPrefix := ""
+		if *params {
+			items = queryParamNames(original)
+			keyPrefix = hostOf(original) + " "
+		}
+		for _, item := range items {
+			if seen[keyPrefix+item] {
+				continue
+			}
+			seen[keyPrefix+item] = true
+			harvested = append(harvested, item)
+			fmt.Println(item)
+		}
+	})
+
+	if *outputFile != "" && len(harvested) > 0 {
+		if err := writeUrlsToFile(*outputFile, harvested, f.gzipLevel); err != nil {
+			fatalf("Error writing to output file: %v", err)
+		}
+	}
+}
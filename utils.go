@@ -2,21 +2,294 @@ package main
 
 import (
 	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/aleister1102/timetraveller/pkg/timetraveller"
 )
 
-func writeUrlsToFile(filename string, urls []string) error {
-	file, err := os.Create(filename)
+// writeUrlsToFile writes one URL per line to filename.
+// Files ending in ".gz" are gzip-compressed at the given level.
+func writeUrlsToFile(filename string, urls []string, gzipLevel int) error {
+	out, err := createURLFile(filename, gzipLevel, false)
 	if err != nil {
 		return err
 	}
-	defer file.Close()
-
-	writer := bufio.NewWriter(file)
 	for _, url := range urls {
-		if _, err := writer.WriteString(url + "\n"); err != nil {
+		if err := out.write(url); err != nil {
+			out.Close()
 			return err
 		}
 	}
-	return writer.Flush()
+	return out.Close()
+}
+
+// urlFile writes one URL per line to a file as results arrive. Files ending in
+// ".gz" are gzip-compressed.
+type urlFile struct {
+	file   *os.File
+	gz     *gzip.Writer
+	writer *bufio.Writer
+	count  int
+}
+
+// checkGzipLevel validates -gzip-level for commands that write output files.
+func checkGzipLevel(fs *flag.FlagSet) error {
+	if fs.Lookup("gzip-level") == nil {
+		return nil
+	}
+	level, _ := strconv.Atoi(flagValue(fs, "gzip-level"))
+	if level < gzip.HuffmanOnly || level > gzip.BestCompression {
+		return fmt.Errorf("invalid -gzip-level %d: must be between %d and %d", level, gzip.HuffmanOnly, gzip.BestCompression)
+	}
+	return nil
+}
+
+// createURLFile creates filename, or appends to it if appendTo is set; an
+// appended .gz file gains a further gzip member, which readers concatenate.
+func createURLFile(filename string, gzipLevel int, appendTo bool) (*urlFile, error) {
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if appendTo {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+	file, err := os.OpenFile(filename, flags, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	u := &urlFile{file: file}
+	var out io.Writer = file
+	if strings.HasSuffix(filename, ".gz") {
+		if u.gz, err = gzip.NewWriterLevel(file, gzipLevel); err != nil {
+			file.Close()
+			return nil, err
+		}
+		out = u.gz
+	}
+	u.writer = bufio.NewWriter(out)
+	return u, nil
+}
+
+func (u *urlFile) write(url string) error {
+	u.count++
+	_, err := u.writer.WriteString(url + "\n")
+	return err
+}
+
+// flush pushes the URLs written so far to the file.
+func (u *urlFile) flush() error {
+	if err := u.writer.Flush(); err != nil {
+		return err
+	}
+	if u.gz != nil {
+		return u.gz.Flush()
+	}
+	return nil
+}
+
+// Close flushes the remaining URLs and closes the file.
+func (u *urlFile) Close() error {
+	err := u.writer.Flush()
+	if u.gz != nil {
+		if gzErr := u.gz.Close(); err == nil {
+			err = gzErr
+		}
+	}
+	if closeErr := u.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// hostOf returns the lowercased host of a target, accepting inputs without a scheme.
+func hostOf(target string) string {
+	raw := target
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+// hostCap admits at most max URLs per host, counting the URLs it turns away.
+type hostCap struct {
+	max     int
+	perHost map[string]int
+	skipped atomic.Int64
+}
+
+func newHostCap(max int) *hostCap {
+	return &hostCap{max: max, perHost: make(map[string]int)}
+}
+
+// admit reports whether u is within its host's cap. It is not safe for
+// concurrent use.
+func (c *hostCap) admit(u string) bool {
+	host := hostOf(u)
+	if c.perHost[host] >= c.max {
+		c.skipped.Add(1)
+		return false
+	}
+	c.perHost[host]++
+	return true
+}
+
+// resultKey returns a stable hash of the fields that identify a result's outcome.
+func resultKey(r timetraveller.ProcessResult) [sha256.Size]byte {
+	errText := ""
+	if r.Error != nil {
+		errText = r.Error.Error()
+	}
+	return sha256.Sum256([]byte(strings.Join([]string{r.URL, r.Status, r.OldestURL, errText}, "\x00")))
+}
+
+// readArchiveMapping reads a CSV file of "original,expected archive URL" rows.
+// It returns the mapping and the original URLs in file order.
+func readArchiveMapping(filename string) (map[string]string, []string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = 2
+	reader.TrimLeadingSpace = true
+
+	mapping := make(map[string]string)
+	var originals []string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		original := strings.TrimSpace(record[0])
+		if _, dup := mapping[original]; !dup {
+			originals = append(originals, original)
+		}
+		mapping[original] = strings.TrimSpace(record[1])
+	}
+	return mapping, originals, nil
+}
+
+// sameArchiveURL compares two archive URLs, ignoring the http/https scheme.
+func sameArchiveURL(a, b string) bool {
+	strip := func(s string) string {
+		s = strings.TrimPrefix(s, "https://")
+		return strings.TrimPrefix(s, "http://")
+	}
+	return strip(a) == strip(b)
+}
+
+// splitList splits a comma-separated flag value, dropping empty items.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// availabilityMatrix reports, for each checkpoint, whether any snapshot was
+// captured within window of it.
+func availabilityMatrix(snapshots []timetraveller.SnapshotEntry, checkpoints []time.Time, window time.Duration) []bool {
+	present := make([]bool, len(checkpoints))
+	for _, entry := range snapshots {
+		captured, err := timetraveller.ParseTimestamp(entry.Field(timetraveller.FieldTimestamp))
+		if err != nil {
+			continue
+		}
+		for i, checkpoint := range checkpoints {
+			diff := captured.Sub(checkpoint)
+			if diff < 0 {
+				diff = -diff
+			}
+			if diff <= window {
+				present[i] = true
+			}
+		}
+	}
+	return present
+}
+
+// formatAvailability renders an availability row as "label:+" / "label:-" pairs.
+func formatAvailability(labels []string, present []bool) string {
+	cells := make([]string, len(labels))
+	for i, label := range labels {
+		mark := "-"
+		if present[i] {
+			mark = "+"
+		}
+		cells[i] = label + ":" + mark
+	}
+	return strings.Join(cells, " ")
+}
+
+// countTrue returns how many of the given conditions hold.
+func countTrue(conditions ...bool) int {
+	n := 0
+	for _, c := range conditions {
+		if c {
+			n++
+		}
+	}
+	return n
+}
+
+// isDigits reports whether s is non-empty and consists only of ASCII digits.
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// queryParamNames returns the names of the query parameters in rawURL, in order
+// of first appearance.
+func queryParamNames(rawURL string) []string {
+	i := strings.Index(rawURL, "?")
+	if i < 0 {
+		return nil
+	}
+	query := rawURL[i+1:]
+	if j := strings.Index(query, "#"); j >= 0 {
+		query = query[:j]
+	}
+	var names []string
+	seen := make(map[string]bool)
+	for _, pair := range strings.FieldsFunc(query, func(r rune) bool { return r == '&' || r == ';' }) {
+		name, _, _ := strings.Cut(pair, "=")
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	return names
 }
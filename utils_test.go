@@ -0,0 +1,171 @@
+package main
+
+import (
+	"compress/gzip"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aleister1102/timetraveller/pkg/timetraveller"
+)
+
+func TestHostCapEnqueuesAtMostKPerHost(t *testing.T) {
+	tests := []struct {
+		name        string
+		max         int
+		input       []string
+		wantQueued  []string
+		wantSkipped int64
+	}{
+		{
+			name: "host over the cap",
+			max:  2,
+			input: []string{
+				"https://a.example/1", "https://a.example/2", "https://a.example/3", "https://a.example/4",
+			},
+			wantQueued:  []string{"https://a.example/1", "https://a.example/2"},
+			wantSkipped: 2,
+		},
+		{
+			name: "hosts capped independently",
+			max:  1,
+			input: []string{
+				"https://a.example/1", "https://b.example/1", "https://a.example/2", "b.example/2", "https://c.example/",
+			},
+			wantQueued:  []string{"https://a.example/1", "https://b.example/1", "https://c.example/"},
+			wantSkipped: 2,
+		},
+		{
+			name:        "host case does not escape the cap",
+			max:         1,
+			input:       []string{"https://A.example/1", "https://a.EXAMPLE/2"},
+			wantQueued:  []string{"https://A.example/1"},
+			wantSkipped: 1,
+		},
+		{
+			name:       "hosts under the cap",
+			max:        3,
+			input:      []string{"https://a.example/1", "https://a.example/2"},
+			wantQueued: []string{"https://a.example/1", "https://a.example/2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			perHost := newHostCap(tt.max)
+			var queued []string
+			for u := range filterURLs(sendURLs(tt.input), perHost.admit) {
+				queued = append(queued, u)
+			}
+			if !slices.Equal(queued, tt.wantQueued) {
+				t.Errorf("queued %q, want %q", queued, tt.wantQueued)
+			}
+			if got := perHost.skipped.Load(); got != tt.wantSkipped {
+				t.Errorf("skipped %d, want %d", got, tt.wantSkipped)
+			}
+		})
+	}
+}
+
+func TestWriteUrlsToFileGzipLevel(t *testing.T) {
+	urls := []string{"https://web.archive.org/web/2001/http://a.example/", "https://web.archive.org/web/2002/http://b.example/"}
+	tests := []struct {
+		name     string
+		level    int
+		wantXFL  byte // Extra flags of the gzip header, which record the level
+		wantGzip bool
+	}{
+		{name: "fastest", level: gzip.BestSpeed, wantXFL: 4, wantGzip: true},
+		{name: "best", level: gzip.BestCompression, wantXFL: 2, wantGzip: true},
+		{name: "default", level: gzip.DefaultCompression, wantXFL: 0, wantGzip: true},
+		{name: "plain file ignores the level", level: gzip.BestCompression},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "urls.txt")
+			if tt.wantGzip {
+				path += ".gz"
+			}
+			if err := writeUrlsToFile(path, urls, tt.level); err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantGzip {
+				if len(data) < 10 || data[8] != tt.wantXFL {
+					t.Fatalf("gzip header %x: want extra flags %d for level %d", data[:min(len(data), 10)], tt.wantXFL, tt.level)
+				}
+				gz, err := gzip.NewReader(strings.NewReader(string(data)))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if data, err = io.ReadAll(gz); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if want := strings.Join(urls, "\n") + "\n"; string(data) != want {
+				t.Errorf("content %q, want %q", data, want)
+			}
+		})
+	}
+}
+
+func TestCheckGzipLevel(t *testing.T) {
+	for level, valid := range map[string]bool{"-2": true, "-1": true, "9": true, "-3": false, "10": false} {
+		var f engineFlags
+		fs := flag.NewFlagSet("urls", flag.ContinueOnError)
+		f.registerOutput(fs)
+		if err := fs.Parse([]string{"-gzip-level", level}); err != nil {
+			t.Fatal(err)
+		}
+		if err := checkGzipLevel(fs); (err == nil) != valid {
+			t.Errorf("-gzip-level %s: got error %v, want valid %v", level, err, valid)
+		}
+	}
+	if err := checkGzipLevel(flag.NewFlagSet("fetch", flag.ContinueOnError)); err != nil {
+		t.Errorf("got %v without -gzip-level defined", err)
+	}
+}
+
+func TestAvailabilityMatrix(t *testing.T) {
+	snapshot := func(timestamp string) timetraveller.SnapshotEntry {
+		return timetraveller.SnapshotEntry{"key", timestamp, "https://a.example/", "text/html", "200", "A", "100"}
+	}
+	checkpoint := func(date string) time.Time {
+		at, err := timetraveller.ParseTimestamp(date)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return at
+	}
+	day := 24 * time.Hour
+	tests := []struct {
+		name      string
+		snapshots []timetraveller.SnapshotEntry
+		window    time.Duration
+		want      string
+	}{
+		{"no captures", nil, 30 * day, "2010:- 201506:- 20200101:-"},
+		{"capture on a checkpoint", []timetraveller.SnapshotEntry{snapshot("20150601000000")}, 0, "2010:- 201506:+ 20200101:-"},
+		{"capture within the window before", []timetraveller.SnapshotEntry{snapshot("20091215000000")}, 30 * day, "2010:+ 201506:- 20200101:-"},
+		{"capture within the window after", []timetraveller.SnapshotEntry{snapshot("20200131000000")}, 30 * day, "2010:- 201506:- 20200101:+"},
+		{"capture just outside the window", []timetraveller.SnapshotEntry{snapshot("20200201000000")}, 30 * day, "2010:- 201506:- 20200101:-"},
+		{"one capture covering two checkpoints", []timetraveller.SnapshotEntry{snapshot("20121001000000")}, 1100 * day, "2010:+ 201506:+ 20200101:-"},
+		{"unparsable timestamp ignored", []timetraveller.SnapshotEntry{snapshot("garbage")}, 30 * day, "2010:- 201506:- 20200101:-"},
+	}
+	labels := []string{"2010", "201506", "20200101"}
+	checkpoints := []time.Time{checkpoint("2010"), checkpoint("201506"), checkpoint("20200101")}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatAvailability(labels, availabilityMatrix(tt.snapshots, checkpoints, tt.window)); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
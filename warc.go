@@ -0,0 +1,134 @@
+package main
+
+import (
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aleister1102/timetraveller/pkg/timetraveller"
+)
+
+// warcWriter writes downloaded snapshots as WARC 1.1 request/response record
+// pairs. Files ending in ".gz" get one gzip member per record, as is standard
+// for .warc.gz files.
+type warcWriter struct {
+	file *os.File
+	gzip bool
+}
+
+func newWARCWriter(filename string) (*warcWriter, error) {
+	file, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+	w := &warcWriter{file: file, gzip: strings.HasSuffix(filename, ".gz")}
+	info := "software: timetraveller\r\nformat: WARC File Format 1.1\r\n"
+	if err := w.writeRecord("warcinfo", "application/warc-fields", []byte(info), [][2]string{
+		{"WARC-Date", time.Now().UTC().Format(time.RFC3339)},
+		{"WARC-Filename", filename},
+	}); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+// writeSnapshot appends a request and a response record for a snapshot body.
+// The response headers are synthesized from the CDX fields, since the archive
+// serves the capture rather than the original response.
+func (w *warcWriter) writeSnapshot(entry timetraveller.SnapshotEntry, body []byte) error {
+	original := entry.Field(timetraveller.FieldOriginal)
+	target, err := url.Parse(original)
+	if err != nil {
+		return fmt.Errorf("invalid original URL %q: %w", original, err)
+	}
+	captured, err := timetraveller.ParseTimestamp(entry.Field(timetraveller.FieldTimestamp))
+	if err != nil {
+		return err
+	}
+	date := captured.UTC().Format(time.RFC3339)
+
+	status, err := strconv.Atoi(entry.Field(timetraveller.FieldStatusCode))
+	if err != nil {
+		status = http.StatusOK
+	}
+	mimetype := entry.Field(timetraveller.FieldMimetype)
+	if mimetype == "" || mimetype == "unk" {
+		mimetype = "application/octet-stream"
+	}
+	response := fmt.Sprintf("HTTP/1.1 %d %s\r\nContent-Type: %s\r\nContent-Length: %d\r\n\r\n", status, http.StatusText(status), mimetype, len(body))
+	responseBlock := append([]byte(response), body...)
+
+	digest := sha1.Sum(body)
+	responseID := newWARCRecordID()
+	if err := w.writeRecord("response", "application/http;msgtype=response", responseBlock, [][2]string{
+		{"WARC-Record-ID", responseID},
+		{"WARC-Target-URI", original},
+		{"WARC-Date", date},
+		{"WARC-Payload-Digest", "sha1:" + base32.StdEncoding.EncodeToString(digest[:])},
+	}); err != nil {
+		return err
+	}
+
+	request := fmt.Sprintf("GET %s HTTP/1.1\r\nHost: %s\r\n\r\n", target.RequestURI(), target.Host)
+	return w.writeRecord("request", "application/http;msgtype=request", []byte(request), [][2]string{
+		{"WARC-Target-URI", original},
+		{"WARC-Date", date},
+		{"WARC-Concurrent-To", responseID},
+	})
+}
+
+// writeRecord writes one record. A WARC-Record-ID is generated unless given.
+func (w *warcWriter) writeRecord(recordType, contentType string, block []byte, headers [][2]string) error {
+	var header strings.Builder
+	header.WriteString("WARC/1.1\r\n")
+	header.WriteString("WARC-Type: " + recordType + "\r\n")
+	hasID := false
+	for _, h := range headers {
+		hasID = hasID || h[0] == "WARC-Record-ID"
+		header.WriteString(h[0] + ": " + h[1] + "\r\n")
+	}
+	if !hasID {
+		header.WriteString("WARC-Record-ID: " + newWARCRecordID() + "\r\n")
+	}
+	header.WriteString("Content-Type: " + contentType + "\r\n")
+	header.WriteString(fmt.Sprintf("Content-Length: %d\r\n\r\n", len(block)))
+
+	var out io.Writer = w.file
+	var gz *gzip.Writer
+	if w.gzip {
+		gz = gzip.NewWriter(w.file)
+		out = gz
+	}
+	for _, part := range [][]byte{[]byte(header.String()), block, []byte("\r\n\r\n")} {
+		if _, err := out.Write(part); err != nil {
+			return err
+		}
+	}
+	if gz != nil {
+		return gz.Close()
+	}
+	return nil
+}
+
+func (w *warcWriter) Close() error {
+	return w.file.Close()
+}
+
+// newWARCRecordID returns a random UUID URN.
+func newWARCRecordID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = (b[6] & 0x0f) | 0x40 // Version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("<urn:uuid:%x-%x-%x-%x-%x>", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
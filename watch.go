@@ -0,0 +1,46 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+// defaultWatchState is the state file of "watch" when -state is not given.
+const defaultWatchState = "timetraveller-watch.json"
+
+func runWatch(args []string) {
+	var f checkFlags
+	fs := flag.NewFlagSet("watch", flag.ExitOnError)
+	f.register(fs)
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: timetraveller watch [options] <url1> [url2 ...]\n")
+		fmt.Fprintf(os.Stderr, "Reports only the URLs that gained captures since the last run, tracked in the -state file (default %s).\n", defaultWatchState)
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	parseFlags(fs, args)
+	f.watchDefaults()
+	formatTemplate := f.validate(fs)
+
+	if fs.NArg() == 0 && !stdinPiped() && f.verifyMap == "" {
+		fs.Usage()
+		os.Exit(exitUsage)
+	}
+	input, err := f.inputURLs(fs.Args())
+	if err != nil {
+		usageFatalf("%v", err)
+	}
+	os.Exit(f.checkRepeatedly(input, formatTemplate))
+}
+
+// watchDefaults turns check options into those of "watch", which only reports
+// the URLs with new captures, tracked in defaultWatchState unless -state is
+// given.
+func (f *checkFlags) watchDefaults() {
+	if f.stateFile == "" {
+		f.stateFile = defaultWatchState
+	}
+	f.changedOnly = true
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestWatchDefaults(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      []string
+		wantState string
+	}{
+		{"default state file", nil, defaultWatchState},
+		{"-state kept", []string{"-state", "mine.json"}, "mine.json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var f checkFlags
+			fs := flag.NewFlagSet("watch", flag.ContinueOnError)
+			f.register(fs)
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			f.watchDefaults()
+			if f.stateFile != tt.wantState || !f.changedOnly {
+				t.Errorf("got state %q and changed-only %v, want %q and true", f.stateFile, f.changedOnly, tt.wantState)
+			}
+		})
+	}
+}
+
+func TestWatchReportsNewCaptures(t *testing.T) {
+	// Each pass sees the captures of its own row: the URL is new on the first
+	// pass, unchanged on the second and gains a capture on the third.
+	passes := [][]string{
+		{"20010101000000"},
+		{"20010101000000"},
+		{"20010101000000", "20200101000000"},
+	}
+	var f checkFlags
+	var requests atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pass := int(requests.Add(1)) - 1
+		if pass >= len(passes)-1 {
+			// Last pass: stop once its lookup is in flight.
+			f.shutdown().interrupt()
+		}
+		rows := [][]string{cdxHeader}
+		for _, ts := range passes[min(pass, len(passes)-1)] {
+			rows = append(rows, capture("https://a.example/", ts, "text/html", ts))
+		}
+		json.NewEncoder(w).Encode(rows)
+	}))
+	defer server.Close()
+
+	state := filepath.Join(t.TempDir(), "state.json")
+	fs := flag.NewFlagSet("watch", flag.ContinueOnError)
+	f.register(fs)
+	if err := fs.Parse([]string{"-no-progress", "-color", "never", "-retries", "0", "-cdx-url", server.URL,
+		"-latest", "-every", "10ms", "-state", state}); err != nil {
+		t.Fatal(err)
+	}
+	if err := setupColor(fs); err != nil {
+		t.Fatal(err)
+	}
+	f.watchDefaults()
+	formatTemplate := f.validate(fs)
+	var exitCode int
+	out := captureStdout(t, func() {
+		exitCode = f.checkRepeatedly(sendURLs([]string{"https://a.example/"}), formatTemplate)
+	})
+
+	want := []string{
+		"[+] https://a.example/ - Snapshots: 1 - Latest: http://web.archive.org/web/20010101000000/https://a.example/",
+		"[+] https://a.example/ - Snapshots: 2 - Latest: http://web.archive.org/web/20200101000000/https://a.example/",
+	}
+	if got := strings.Split(strings.TrimSpace(out), "\n"); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if n := requests.Load(); n != int64(len(passes)) {
+		t.Errorf("%d passes, want %d", n, len(passes))
+	}
+	if exitCode != exitFound {
+		t.Errorf("exit code %d, want %d", exitCode, exitFound)
+	}
+	data, err := os.ReadFile(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"https://a.example/": "20200101000000"`) {
+		t.Errorf("state file %s does not record the latest capture", data)
+	}
+}
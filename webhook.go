@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/aleister1102/timetraveller/pkg/timetraveller"
+)
+
+const (
+	// webhookTimeout bounds one POST of a webhook sink.
+	webhookTimeout = 30 * time.Second
+	// webhookRetryDelay is the wait before the first retry of a failed POST,
+	// doubled on each further attempt.
+	webhookRetryDelay = time.Second
+)
+
+// webhookSink POSTs results as JSON to a URL: each result as an object, or
+// with a batch size above one, groups of results as an array. Failed POSTs
+// are retried; a delivery that still fails is logged and dropped rather than
+// stopping the run.
+type webhookSink struct {
+	url          string
+	name         string // Stands for url in messages
+	client       *http.Client
+	all, changes bool
+	foundOnly    bool // Skip results without a snapshot, as -webhook does
+	batch        int
+	retries      int
+	pending      []jsonResult
+	log          *slog.Logger // Where retries go with -v, or nil
+}
+
+// newWebhookSink returns a webhook sink posting to target with the -webhook-*
+// settings.
+func (f *checkFlags) newWebhookSink(target string, foundOnly bool) *webhookSink {
+	return &webhookSink{url: target, name: target, client: &http.Client{Timeout: webhookTimeout}, all: f.allSnapshots,
+		changes: f.changes, foundOnly: foundOnly, batch: f.webhookBatch, retries: f.webhookRetries, log: f.logger()}
+}
+
+// validateWebhookURL rejects webhook targets that are not http(s) URLs.
+func validateWebhookURL(target string) error {
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("expected an http(s) URL, e.g. https://example.com/hook")
+	}
+	return nil
+}
+
+func (s *webhookSink) Write(r checkResult) error {
+	if s.foundOnly && r.Status != timetraveller.StatusFound {
+		return nil
+	}
+	out := r.json(s.all, s.changes)
+	if s.batch <= 1 {
+		s.post(out, 1)
+		return nil
+	}
+	s.pending = append(s.pending, out)
+	if len(s.pending) >= s.batch {
+		return s.Flush()
+	}
+	return nil
+}
+
+// Flush posts the results of an incomplete batch.
+func (s *webhookSink) Flush() error {
+	if len(s.pending) > 0 {
+		s.post(s.pending, len(s.pending))
+		s.pending = nil
+	}
+	return nil
+}
+
+func (s *webhookSink) Close() error { return s.Flush() }
+
+// post sends v, which holds count results, retrying network errors, 429s and
+// 5xx responses.
+func (s *webhookSink) post(v any, count int) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		slog.Error("Webhook delivery failed", "webhook", s.name, "results", count, "error", err)
+		return
+	}
+	delay := webhookRetryDelay
+	for attempt := 0; ; attempt++ {
+		retry, err := s.send(data)
+		if err == nil {
+			return
+		}
+		if !retry || attempt >= s.retries {
+			slog.Error("Webhook delivery failed", "webhook", s.name, "results", count, "attempts", attempt+1, "error", err)
+			return
+		}
+		if s.log != nil {
+			s.log.Info("Retrying webhook delivery", "webhook", s.name, "delay", delay, "error", err)
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// send POSTs data once, reporting whether a failure is worth retrying.
+func (s *webhookSink) send(data []byte) (retry bool, err error) {
+	resp, err := s.client.Post(s.url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			// Keep the URL, which may embed a token, out of messages.
+			err = urlErr.Err
+		}
+		return true, err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		retry = resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		return retry, fmt.Errorf("answered %s", resp.Status)
+	}
+	return false, nil
+}
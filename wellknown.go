@@ -0,0 +1,117 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+
+	"github.com/aleister1102/timetraveller/pkg/timetraveller"
+)
+
+// wellKnownFile describes a per-host file, such as robots.txt, whose archived
+// versions are downloaded and mined for entries.
+type wellKnownFile struct {
+	command string
+	path    string // Path of the file below each host, e.g. "/robots.txt"
+	entries string // What extract returns, for help text
+	maxSize int64
+	extract func(body []byte) []string
+}
+
+// runWellKnown downloads the archived versions of file for each input host
+// and prints every entry they ever listed, with the timestamp of the earliest
+// capture listing it, deduplicated per host.
+func runWellKnown(file wellKnownFile, args []string) {
+	var f engineFlags
+	fs := flag.NewFlagSet(file.command, flag.ExitOnError)
+	f.register(fs)
+	outputFile := fs.String("o", "", "File to write "+file.entries+" to")
+	yearly := fs.Bool("yearly", false, "Only use one "+file.path[1:]+" capture per year instead of every distinct version")
+	downloadWorkers := fs.Int("dt", 5, "Number of concurrent downloads")
+	maxSize := fs.Int64("max-size", file.maxSize, "Maximum "+file.path[1:]+" size in bytes (0 = unlimited)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: timetraveller %s [options] <host1> [host2 ...]\n", file.command)
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	parseFlags(fs, args)
+
+	hosts, inputStats, err := f.readInput(fs.Args())
+	if err != nil {
+		usageFatalf("%v", err)
+	}
+	inputStats.report()
+	if len(hosts) == 0 {
+		fs.Usage()
+		os.Exit(exitUsage)
+	}
+	fileURLs := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		if h := hostOf(host); h != "" {
+			fileURLs = append(fileURLs, h+file.path)
+		}
+	}
+
+	opts, err := f.lookupOptions()
+	if err != nil {
+		usageFatalf("%v", err)
+	}
+	if *yearly {
+		opts.Collapse = append(opts.Collapse, "timestamp:4")
+	} else {
+		opts.Collapse = append(opts.Collapse, "digest")
+	}
+	opts.Raw = true
+
+	pickAll := func(result timetraveller.ProcessResult) []timetraveller.SnapshotEntry {
+		return result.Snapshots
+	}
+	// Record when each entry was first seen; downloads finish out of order.
+	type listing struct{ host, timestamp, entry string }
+	firstSeen := make(map[[2]string]int)
+	var found []listing
+	downloads := startDownloads(&f, sendURLs(fileURLs), opts, downloadConfig{
+		workers: *downloadWorkers,
+		maxSize: *maxSize,
+		pick:    pickAll,
+	})
+	for d := range downloads {
+		if d.error != nil {
+			slog.Error("Download failed", "url", d.job.entry.ArchiveURL(), "error", d.error)
+			continue
+		}
+		host := hostOf(d.job.inputURL)
+		timestamp := d.job.entry.Field(timetraveller.FieldTimestamp)
+		for _, entry := range file.extract(d.body) {
+			key := [2]string{host, entry}
+			if i, ok := firstSeen[key]; ok {
+				if timestamp < found[i].timestamp {
+					found[i].timestamp = timestamp
+				}
+				continue
+			}
+			firstSeen[key] = len(found)
+			found = append(found, listing{host, timestamp, entry})
+		}
+	}
+
+	sort.SliceStable(found, func(i, j int) bool {
+		if found[i].host != found[j].host {
+			return found[i].host < found[j].host
+		}
+		return found[i].timestamp < found[j].timestamp
+	})
+	entries := make([]string, 0, len(found))
+	for _, l := range found {
+		fmt.Printf("%s %s %s\n", l.host, l.timestamp, l.entry)
+		entries = append(entries, l.entry)
+	}
+
+	if *outputFile != "" && len(entries) > 0 {
+		if err := writeUrlsToFile(*outputFile, entries, f.gzipLevel); err != nil {
+			fatalf("Error writing to output file: %v", err)
+		}
+	}
+}